@@ -0,0 +1,150 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sync"
+)
+
+//AccessRule allow messages from remote service with type Source
+type AccessRule struct {
+	Source    ServiceType
+	Names     []string    //optional patterns of remote name, see path.Match, empty for all services of Source
+	Messages  []MessageID //allowed message id
+	Resources []uint      //allow all messages of resource
+	Operates  []uint      //allow all messages of operate
+}
+
+//AccessPolicy only messages allowed by rules accepted from service types listed,
+//system messages and messages from endpoint itself or submodules never checked
+type AccessPolicy struct {
+	Rules        []AccessRule
+	DenyUnlisted bool //deny services with type not listed in any rule
+}
+
+func (rule AccessRule) validate() error {
+	for _, pattern := range rule.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid name pattern '%s': %s", pattern, err.Error())
+		}
+	}
+	if 0 == len(rule.Messages) && 0 == len(rule.Resources) && 0 == len(rule.Operates) {
+		return fmt.Errorf("no message allowed for service type %d", rule.Source)
+	}
+	return nil
+}
+
+func (rule AccessRule) matchName(name string) bool {
+	if 0 == len(rule.Names) {
+		return true
+	}
+	for _, pattern := range rule.Names {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule AccessRule) allow(id MessageID) bool {
+	for _, allowed := range rule.Messages {
+		if allowed == id {
+			return true
+		}
+	}
+	for _, resource := range rule.Resources {
+		if resource == GetMessageResource(id) {
+			return true
+		}
+	}
+	for _, operate := range rule.Operates {
+		if operate == GetMessageOperate(id) {
+			return true
+		}
+	}
+	return false
+}
+
+//Allow check message id from remote service
+func (policy AccessPolicy) Allow(source ServiceType, name string, id MessageID) bool {
+	var listed = false
+	for _, rule := range policy.Rules {
+		if source != rule.Source {
+			continue
+		}
+		listed = true
+		if rule.matchName(name) && rule.allow(id) {
+			return true
+		}
+	}
+	return !listed && !policy.DenyUnlisted
+}
+
+func (policy AccessPolicy) validate() error {
+	if 0 == len(policy.Rules) {
+		return errors.New("no access rule specified")
+	}
+	for _, rule := range policy.Rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type accessControl struct {
+	lock   sync.RWMutex
+	policy *AccessPolicy //nil for all allowed
+}
+
+func (control *accessControl) set(policy *AccessPolicy) {
+	control.lock.Lock()
+	control.policy = policy
+	control.lock.Unlock()
+}
+
+func (control *accessControl) get() *AccessPolicy {
+	control.lock.RLock()
+	defer control.lock.RUnlock()
+	return control.policy
+}
+
+//WithAccessPolicy check messages from remote services before dispatch
+func WithAccessPolicy(policy AccessPolicy) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		return endpoint.SetAccessPolicy(policy)
+	})
+}
+
+//SetAccessPolicy replace current access policy
+func (endpoint *EndpointService) SetAccessPolicy(policy AccessPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	endpoint.access.set(&policy)
+	return nil
+}
+
+//ClearAccessPolicy allow all messages from remote services
+func (endpoint *EndpointService) ClearAccessPolicy() {
+	endpoint.access.set(nil)
+}
+
+//checkAccess return false when message denied, error response sent for denied request
+func (endpoint *EndpointService) checkAccess(incoming incomingMessage) bool {
+	var policy = endpoint.access.get()
+	if nil == policy || !incoming.Remote {
+		//local message
+		return true
+	}
+	var msg = incoming.Message
+	if policy.Allow(incoming.Type, incoming.Name, msg.GetID()) {
+		return true
+	}
+	log.Printf("<endpoint> message %08X from '%s' (type %d) denied by access policy",
+		uint32(msg.GetID()), incoming.Name, incoming.Type)
+	endpoint.replyError(msg, fmt.Errorf("message %08X not allowed", uint32(msg.GetID())))
+	return false
+}
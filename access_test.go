@@ -0,0 +1,123 @@
+package framework
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_AccessPolicy(t *testing.T) {
+	var policy = AccessPolicy{Rules: []AccessRule{
+		{Source: ServiceTypeCell, Messages: []MessageID{QueryZoneRequest}, Resources: []uint{ResourceInstance}},
+		{Source: ServiceTypeCell, Names: []string{"Cell_admin*"}, Messages: []MessageID{DeleteGuestRequest}},
+	}}
+	if err := policy.validate(); err != nil {
+		t.Fatalf("validate policy fail: %s", err.Error())
+	}
+	var cases = []struct {
+		Source  ServiceType
+		Name    string
+		ID      MessageID
+		Allowed bool
+	}{
+		{ServiceTypeCell, "Cell_01", QueryZoneRequest, true},
+		{ServiceTypeCell, "Cell_01", InstanceMigratedEvent, true},
+		{ServiceTypeCell, "Cell_01", DeleteGuestRequest, false},
+		{ServiceTypeCell, "Cell_admin01", DeleteGuestRequest, true},
+		{ServiceTypeCell, "Cell_01", ResetSystemRequest, false},
+		{ServiceTypeImage, "Image_01", ResetSystemRequest, true},
+	}
+	for _, current := range cases {
+		if allowed := policy.Allow(current.Source, current.Name, current.ID); allowed != current.Allowed {
+			t.Fatalf("message %08X from %s allowed: %t", uint32(current.ID), current.Name, allowed)
+		}
+	}
+	policy.DenyUnlisted = true
+	if policy.Allow(ServiceTypeImage, "Image_01", ResetSystemRequest) {
+		t.Fatal("unlisted service allowed")
+	}
+	var invalid = AccessPolicy{Rules: []AccessRule{{Source: ServiceTypeCell, Names: []string{"["}, Messages: []MessageID{QueryZoneRequest}}}}
+	if err := invalid.validate(); err == nil {
+		t.Fatal("invalid pattern accepted")
+	}
+	invalid = AccessPolicy{Rules: []AccessRule{{Source: ServiceTypeCell}}}
+	if err := invalid.validate(); err == nil {
+		t.Fatal("empty rule accepted")
+	}
+}
+
+func Test_EndpointAccess(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core, cell = startMemoryPair(t, network)
+	if err := core.SetAccessPolicy(AccessPolicy{Rules: []AccessRule{
+		{Source: ServiceTypeCell, Resources: []uint{ResourceGuest}, Messages: []MessageID{QueryZoneRequest}},
+	}}); err != nil {
+		t.Fatalf("set access policy fail: %s", err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, _ := CreateJsonMessage(ResetSystemRequest)
+	resp, err := cell.SendRequest(ctx, request, core.GetName())
+	if err != nil {
+		t.Fatalf("send request fail: %s", err.Error())
+	}
+	if resp.IsSuccess() || "" == resp.GetError() {
+		t.Fatal("denied request not answered with error")
+	}
+	var send = func(id MessageID) {
+		msg, _ := CreateJsonMessage(id)
+		if err := cell.SendMessage(msg, core.GetName()); err != nil {
+			t.Fatalf("send message fail: %s", err.Error())
+		}
+	}
+	send(InstanceMigratedEvent)
+	send(GuestStartedEvent)
+	receiveEvent(t, core.MessageChan, GuestStartedEvent)
+
+	//local message never checked
+	self, _ := CreateJsonMessage(ResetSystemRequest)
+	if err = core.SendToSelf(self); err != nil {
+		t.Fatal(err)
+	}
+	receiveEvent(t, core.MessageChan, ResetSystemRequest)
+
+	core.ClearAccessPolicy()
+	send(InstanceMigratedEvent)
+	receiveEvent(t, core.MessageChan, InstanceMigratedEvent)
+}
+
+func Test_AccessAfterDisconnected(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core, cell = startMemoryPair(t, network)
+	if err := core.SetAccessPolicy(AccessPolicy{Rules: []AccessRule{
+		{Source: ServiceTypeCell, Messages: []MessageID{GuestStartedEvent}},
+	}}); err != nil {
+		t.Fatalf("set access policy fail: %s", err.Error())
+	}
+	//block main routine of core with allowed events
+	for i := 0; i <= cap(core.MessageChan); i++ {
+		msg, _ := CreateJsonMessage(GuestStartedEvent)
+		if err := cell.SendMessage(msg, core.GetName()); err != nil {
+			t.Fatalf("send message fail: %s", err.Error())
+		}
+	}
+	denied, _ := CreateJsonMessage(InstanceMigratedEvent)
+	if err := cell.SendMessage(denied, core.GetName()); err != nil {
+		t.Fatalf("send message fail: %s", err.Error())
+	}
+	waitCondition(t, func() bool {
+		return 1 == len(core.incomingMessageChan)
+	}, "message queued")
+	//origin recorded when arrived, message denied after connection removed
+	if err := cell.Stop(); err != nil {
+		t.Fatalf("stop cell fail: %s", err.Error())
+	}
+	waitCondition(t, func() bool {
+		_, exists := core.connections.get(cell.GetName())
+		return !exists
+	}, "connection removed")
+	for i := 0; i <= cap(core.MessageChan); i++ {
+		receiveEvent(t, core.MessageChan, GuestStartedEvent)
+	}
+	flushMainRoutine(t, core)
+}
@@ -0,0 +1,132 @@
+package framework
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//endpoint role in proof, prevent reflecting proof of one side to another
+const (
+	authRoleAcceptor = "accept"
+	authRoleDialer   = "dial"
+	authNonceLength  = 16
+	//key of session seal derived with role prefixed, never equal to proof
+	authSealPrefix = "seal:"
+)
+
+func generateNonce() (string, error) {
+	var buf = make([]byte, authNonceLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+//computeProof HMAC-SHA256 over role, domain, both names and both nonces with the domain key
+func computeProof(key []byte, role, domain, prover, verifier, verifierNonce, proverNonce string) string {
+	var mac = hmac.New(sha256.New, key)
+	for _, field := range []string{role, domain, prover, verifier, verifierNonce, proverNonce} {
+		var length = make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(field)))
+		mac.Write(length)
+		mac.Write([]byte(field))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyProof(key []byte, proof, role, domain, prover, verifier, verifierNonce, proverNonce string) error {
+	if "" == proof {
+		return fmt.Errorf("no authentication proof from '%s'", prover)
+	}
+	received, err := hex.DecodeString(proof)
+	if err != nil {
+		return fmt.Errorf("invalid authentication proof from '%s'", prover)
+	}
+	expected, _ := hex.DecodeString(computeProof(key, role, domain, prover, verifier, verifierNonce, proverNonce))
+	if !hmac.Equal(received, expected) {
+		return fmt.Errorf("authentication of '%s' fail", prover)
+	}
+	return nil
+}
+
+//SetDomainKey enable mutual authentication with a pre-shared key of current domain,
+//remote endpoint without the same key will be rejected during handshake.
+//frames after handshake sealed with keys bound to nonces of both endpoints, so that a relay of handshake
+//can not inject frames, but messages not encrypted without WithEncryption
+func (endpoint *EndpointService) SetDomainKey(key string) error {
+	if "" == key {
+		return errors.New("empty domain key")
+	}
+	endpoint.domainKey = []byte(key)
+	return nil
+}
+
+func (endpoint *EndpointService) authenticationEnabled() bool {
+	return 0 != len(endpoint.domainKey)
+}
+
+//prepareAcceptorProof challenge dialer with a new nonce and prove local identity
+func (endpoint *EndpointService) prepareAcceptorProof(local *handshakeInfo, remote handshakeInfo) (err error) {
+	if "" == remote.Nonce {
+		return errors.New("authentication required")
+	}
+	if local.Nonce, err = generateNonce(); err != nil {
+		return
+	}
+	local.Proof = computeProof(endpoint.domainKey, authRoleAcceptor, endpoint.domain, local.Name, remote.Name,
+		remote.Nonce, local.Nonce)
+	return nil
+}
+
+//verifyAcceptor check proof in handshake reply
+func (endpoint *EndpointService) verifyAcceptor(local, remote handshakeInfo) error {
+	return verifyProof(endpoint.domainKey, remote.Proof, authRoleAcceptor, endpoint.domain, remote.Name, local.Name,
+		local.Nonce, remote.Nonce)
+}
+
+//sendDialerProof answer challenge of acceptor
+func (endpoint *EndpointService) sendDialerProof(conn *frameConn, local, remote handshakeInfo) error {
+	msg, err := CreateJsonMessage(ConnectionAuthenticateEvent)
+	if err != nil {
+		return err
+	}
+	msg.SetString(ParamKeyProof, computeProof(endpoint.domainKey, authRoleDialer, endpoint.domain, local.Name, remote.Name,
+		remote.Nonce, local.Nonce))
+	return conn.WriteMessage(msg)
+}
+
+//sealKey derive key authenticating frames sent by role, bound to names and nonces of current handshake
+func (endpoint *EndpointService) sealKey(role string, dialer, acceptor handshakeInfo) []byte {
+	key, _ := hex.DecodeString(computeProof(endpoint.domainKey, authSealPrefix+role, endpoint.domain, dialer.Name,
+		acceptor.Name, dialer.Nonce, acceptor.Nonce))
+	return key
+}
+
+//sealSession authenticate all frames following handshake
+func (endpoint *EndpointService) sealSession(conn *frameConn, dialing bool, local, remote handshakeInfo) error {
+	if dialing {
+		return conn.Seal(endpoint.sealKey(authRoleDialer, local, remote), endpoint.sealKey(authRoleAcceptor, local, remote))
+	}
+	return conn.Seal(endpoint.sealKey(authRoleAcceptor, remote, local), endpoint.sealKey(authRoleDialer, remote, local))
+}
+
+//verifyDialer wait and check the answer of dialer before connection opened
+func (endpoint *EndpointService) verifyDialer(conn *frameConn, local, remote handshakeInfo) error {
+	conn.SetReadDeadline(time.Now().Add(endpoint.options.HandshakeTimeout))
+	msg, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if msg.GetID() != ConnectionAuthenticateEvent {
+		return fmt.Errorf("unexpected message %08X before authenticated", msg.GetID())
+	}
+	proof, _ := msg.GetString(ParamKeyProof)
+	return verifyProof(endpoint.domainKey, proof, authRoleDialer, endpoint.domain, remote.Name, local.Name,
+		local.Nonce, remote.Nonce)
+}
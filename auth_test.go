@@ -0,0 +1,126 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_AuthenticationProof(t *testing.T) {
+	const (
+		domain   = "nano"
+		acceptor = "Core_001"
+		dialer   = "Cell_001"
+	)
+	var key = []byte("domain secret")
+	dialerNonce, err := generateNonce()
+	if err != nil {
+		t.Fatalf("generate nonce fail: %s", err.Error())
+	}
+	acceptorNonce, err := generateNonce()
+	if err != nil {
+		t.Fatalf("generate nonce fail: %s", err.Error())
+	}
+	var proof = computeProof(key, authRoleAcceptor, domain, acceptor, dialer, dialerNonce, acceptorNonce)
+	if err = verifyProof(key, proof, authRoleAcceptor, domain, acceptor, dialer, dialerNonce, acceptorNonce); err != nil {
+		t.Fatalf("verify proof fail: %s", err.Error())
+	}
+	if err = verifyProof([]byte("another secret"), proof, authRoleAcceptor, domain, acceptor, dialer, dialerNonce, acceptorNonce); err == nil {
+		t.Fatal("proof accepted with different key")
+	}
+	//reflect proof of acceptor as dialer
+	if err = verifyProof(key, proof, authRoleDialer, domain, acceptor, dialer, dialerNonce, acceptorNonce); err == nil {
+		t.Fatal("reflected proof accepted")
+	}
+	//replay proof with new challenge
+	newNonce, _ := generateNonce()
+	if err = verifyProof(key, proof, authRoleAcceptor, domain, acceptor, dialer, newNonce, acceptorNonce); err == nil {
+		t.Fatal("replayed proof accepted")
+	}
+	if err = verifyProof(key, "", authRoleAcceptor, domain, acceptor, dialer, dialerNonce, acceptorNonce); err == nil {
+		t.Fatal("empty proof accepted")
+	}
+}
+
+type authPeer struct {
+	*memoryPeer
+	ConnectedChan chan string
+}
+
+func (peer *authPeer) OnServiceConnected(name string, t ServiceType, remote string) {
+	peer.ConnectedChan <- name
+}
+
+func createAuthPeer(t *testing.T, network *MemoryNetwork, index byte, key string) *authPeer {
+	var peer = &authPeer{createMemoryPeer(t, network, ServiceTypeCore, index), make(chan string, 8)}
+	peer.handler = peer
+	if "" != key {
+		if err := peer.SetDomainKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := peer.Start(); err != nil {
+		t.Fatalf("start %s fail: %s", peer.GetName(), err.Error())
+	}
+	t.Cleanup(func() {
+		peer.Stop()
+	})
+	return peer
+}
+
+func Test_EndpointAuthentication(t *testing.T) {
+	const (
+		domainKey = "domain secret"
+	)
+	var cases = []struct {
+		Name      string
+		DialerKey string
+		Connected bool
+	}{
+		{"wrong key", "other secret", false},
+		{"missing key", "", false},
+		{"matching key", domainKey, true},
+	}
+	for _, current := range cases {
+		var network = CreateMemoryNetwork()
+		var acceptor = createAuthPeer(t, network, 0, domainKey)
+		var dialer = createAuthPeer(t, network, 1, current.DialerKey)
+		var err = dialer.connectRemoteService(acceptor.GetListenAddress(), acceptor.GetListenPort())
+		if !current.Connected {
+			if err == nil {
+				t.Fatalf("connected with %s", current.Name)
+			}
+			t.Logf("%s rejected: %s", current.Name, err.Error())
+			flushMainRoutine(t, acceptor.memoryPeer)
+			flushMainRoutine(t, dialer.memoryPeer)
+			for _, peer := range []*authPeer{acceptor, dialer} {
+				select {
+				case name := <-peer.ConnectedChan:
+					t.Fatalf("%s connected to '%s' with %s", peer.GetName(), name, current.Name)
+				default:
+				}
+				if 0 != len(peer.ListConnections()) {
+					t.Fatalf("connection of %s opened with %s", peer.GetName(), current.Name)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("connect with %s fail: %s", current.Name, err.Error())
+		}
+		for _, peer := range []*authPeer{acceptor, dialer} {
+			select {
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s wait connected timeout", peer.GetName())
+			case <-peer.ConnectedChan:
+			}
+		}
+		//frames sealed in both directions
+		for _, pair := range [][]*authPeer{{dialer, acceptor}, {acceptor, dialer}} {
+			msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+			if err = pair[0].SendMessage(msg, pair[1].GetName()); err != nil {
+				t.Fatalf("send message fail: %s", err.Error())
+			}
+			receiveEvent(t, pair[1].MessageChan, ComputePoolReadyEvent)
+		}
+	}
+}
@@ -0,0 +1,592 @@
+package framework
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+//BinaryMessage serialize with tagged varint fields, much cheaper than json for status report
+type BinaryMessage struct {
+	ID                MessageID
+	Success           bool
+	Sender            string
+	From              SessionID
+	To                SessionID
+	Transaction       TransactionID
+	Error             string
+	BoolParams        map[ParamKey]bool
+	StringParams      map[ParamKey]string
+	UIntParams        map[ParamKey]uint
+	IntParams         map[ParamKey]int
+	FloatParams       map[ParamKey]float64
+	UIntArrayParams   map[ParamKey][]uint64
+	StringArrayParams map[ParamKey][]string
+}
+
+//field tags
+const (
+	binaryTagID = iota + 1
+	binaryTagSuccess
+	binaryTagFrom
+	binaryTagTo
+	binaryTagTransaction
+	binaryTagError
+	binaryTagBool
+	binaryTagString
+	binaryTagUInt
+	binaryTagInt
+	binaryTagFloat
+	binaryTagUIntArray
+	binaryTagStringArray
+)
+
+func CreateBinaryMessage(msg MessageID) (*BinaryMessage, error) {
+	return &BinaryMessage{ID: msg}, nil
+}
+
+func MessageFromBinary(data []byte) (*BinaryMessage, error) {
+	var msg BinaryMessage
+	var decoder = binaryDecoder{data: data}
+	var err = decoder.decode(&msg)
+	return &msg, err
+}
+
+func (msg *BinaryMessage) GetID() MessageID {
+	return msg.ID
+}
+
+func (msg *BinaryMessage) SetID(id MessageID) {
+	msg.ID = id
+}
+
+func (msg *BinaryMessage) IsSuccess() bool {
+	return msg.Success
+}
+func (msg *BinaryMessage) SetSuccess(flag bool) {
+	msg.Success = flag
+}
+func (msg *BinaryMessage) SetSender(value string) {
+	msg.Sender = value
+}
+func (msg *BinaryMessage) GetSender() string {
+	return msg.Sender
+}
+
+func (msg *BinaryMessage) GetFromSession() SessionID {
+	return msg.From
+}
+func (msg *BinaryMessage) SetFromSession(session SessionID) {
+	msg.From = session
+}
+func (msg *BinaryMessage) GetToSession() SessionID {
+	return msg.To
+}
+func (msg *BinaryMessage) SetToSession(session SessionID) {
+	msg.To = session
+}
+
+func (msg *BinaryMessage) SetTransactionID(id TransactionID) {
+	msg.Transaction = id
+}
+func (msg *BinaryMessage) GetTransactionID() TransactionID {
+	return msg.Transaction
+}
+
+func (msg *BinaryMessage) SetError(err string) {
+	msg.Error = err
+}
+func (msg *BinaryMessage) GetError() string {
+	return msg.Error
+}
+
+func (msg *BinaryMessage) GetString(key ParamKey) (string, error) {
+	if msg.StringParams != nil {
+		if value, exists := msg.StringParams[key]; exists {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("no string param for key %d", key)
+}
+
+func (msg *BinaryMessage) GetUInt(key ParamKey) (uint, error) {
+	if msg.UIntParams != nil {
+		if value, exists := msg.UIntParams[key]; exists {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("no uint param for key %d", key)
+}
+
+func (msg *BinaryMessage) GetInt(key ParamKey) (int, error) {
+	if msg.IntParams != nil {
+		if value, exists := msg.IntParams[key]; exists {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("no int param for key %d", key)
+}
+
+func (msg *BinaryMessage) GetFloat(key ParamKey) (float64, error) {
+	if msg.FloatParams != nil {
+		if value, exists := msg.FloatParams[key]; exists {
+			return value, nil
+		}
+	}
+	return 0.0, fmt.Errorf("no float param for key %d", key)
+}
+
+func (msg *BinaryMessage) GetBoolean(key ParamKey) (bool, error) {
+	if msg.BoolParams != nil {
+		if value, exists := msg.BoolParams[key]; exists {
+			return value, nil
+		}
+	}
+	return false, fmt.Errorf("no bool param for key %d", key)
+}
+
+func (msg *BinaryMessage) SetString(key ParamKey, value string) {
+	if msg.StringParams != nil {
+		msg.StringParams[key] = value
+	} else {
+		msg.StringParams = map[ParamKey]string{key: value}
+	}
+}
+
+func (msg *BinaryMessage) SetUInt(key ParamKey, value uint) {
+	if msg.UIntParams != nil {
+		msg.UIntParams[key] = value
+	} else {
+		msg.UIntParams = map[ParamKey]uint{key: value}
+	}
+}
+
+func (msg *BinaryMessage) SetInt(key ParamKey, value int) {
+	if msg.IntParams != nil {
+		msg.IntParams[key] = value
+	} else {
+		msg.IntParams = map[ParamKey]int{key: value}
+	}
+}
+
+func (msg *BinaryMessage) SetFloat(key ParamKey, value float64) {
+	if msg.FloatParams != nil {
+		msg.FloatParams[key] = value
+	} else {
+		msg.FloatParams = map[ParamKey]float64{key: value}
+	}
+}
+
+func (msg *BinaryMessage) SetBoolean(key ParamKey, value bool) {
+	if msg.BoolParams != nil {
+		msg.BoolParams[key] = value
+	} else {
+		msg.BoolParams = map[ParamKey]bool{key: value}
+	}
+}
+
+func (msg *BinaryMessage) SetUIntArray(key ParamKey, value []uint64) {
+	if msg.UIntArrayParams != nil {
+		msg.UIntArrayParams[key] = value
+	} else {
+		msg.UIntArrayParams = map[ParamKey][]uint64{key: value}
+	}
+}
+func (msg *BinaryMessage) GetUIntArray(key ParamKey) ([]uint64, error) {
+	if msg.UIntArrayParams != nil {
+		if value, exists := msg.UIntArrayParams[key]; exists {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("no uint array for key %d", key)
+}
+
+func (msg *BinaryMessage) SetStringArray(key ParamKey, value []string) {
+	if msg.StringArrayParams != nil {
+		msg.StringArrayParams[key] = value
+	} else {
+		msg.StringArrayParams = map[ParamKey][]string{key: value}
+	}
+}
+func (msg *BinaryMessage) GetStringArray(key ParamKey) ([]string, error) {
+	if msg.StringArrayParams != nil {
+		if value, exists := msg.StringArrayParams[key]; exists {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("no string array for key %d", key)
+}
+
+func (msg *BinaryMessage) Serialize() ([]byte, error) {
+	return encodeBinaryMessage(msg)
+}
+
+func (msg *BinaryMessage) GetAllString() map[ParamKey]string {
+	if msg.StringParams != nil {
+		return msg.StringParams
+	}
+	return emptyStringMap
+}
+func (msg *BinaryMessage) GetAllUInt() map[ParamKey]uint {
+	if msg.UIntParams != nil {
+		return msg.UIntParams
+	}
+	return emptyUIntMap
+}
+
+func (msg *BinaryMessage) GetAllInt() map[ParamKey]int {
+	if msg.IntParams != nil {
+		return msg.IntParams
+	}
+	return emptyIntMap
+}
+
+func (msg *BinaryMessage) GetAllFloat() map[ParamKey]float64 {
+	if msg.FloatParams != nil {
+		return msg.FloatParams
+	}
+	return emptyFloatMap
+}
+
+func (msg *BinaryMessage) GetAllBoolean() map[ParamKey]bool {
+	if msg.BoolParams != nil {
+		return msg.BoolParams
+	}
+	return emptyBooleanMap
+}
+
+func (msg *BinaryMessage) GetAllUIntArray() map[ParamKey][]uint64 {
+	if msg.UIntArrayParams != nil {
+		return msg.UIntArrayParams
+	}
+	return emptyUIntArrayMap
+}
+
+func (msg *BinaryMessage) GetAllStringArray() map[ParamKey][]string {
+	if msg.StringArrayParams != nil {
+		return msg.StringArrayParams
+	}
+	return emptyStringArrayMap
+}
+
+//encodeBinaryMessage accept any Message implement, so json message can be sent in binary format
+func encodeBinaryMessage(msg Message) ([]byte, error) {
+	var buf = make([]byte, 0, 128)
+	buf = appendUvarint(buf, binaryTagID, uint64(msg.GetID()))
+	if msg.IsSuccess() {
+		buf = append(buf, binaryTagSuccess)
+	}
+	if 0 != msg.GetFromSession() {
+		buf = appendUvarint(buf, binaryTagFrom, uint64(msg.GetFromSession()))
+	}
+	if 0 != msg.GetToSession() {
+		buf = appendUvarint(buf, binaryTagTo, uint64(msg.GetToSession()))
+	}
+	if 0 != msg.GetTransactionID() {
+		buf = appendUvarint(buf, binaryTagTransaction, uint64(msg.GetTransactionID()))
+	}
+	if "" != msg.GetError() {
+		buf = append(buf, binaryTagError)
+		buf = appendString(buf, msg.GetError())
+	}
+	if params := msg.GetAllBoolean(); 0 != len(params) {
+		buf = append(buf, binaryTagBool)
+		buf = binary.AppendUvarint(buf, uint64(len(params)))
+		for key, value := range params {
+			buf = binary.AppendUvarint(buf, uint64(key))
+			if value {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+			}
+		}
+	}
+	if params := msg.GetAllString(); 0 != len(params) {
+		buf = append(buf, binaryTagString)
+		buf = binary.AppendUvarint(buf, uint64(len(params)))
+		for key, value := range params {
+			buf = binary.AppendUvarint(buf, uint64(key))
+			buf = appendString(buf, value)
+		}
+	}
+	if params := msg.GetAllUInt(); 0 != len(params) {
+		buf = append(buf, binaryTagUInt)
+		buf = binary.AppendUvarint(buf, uint64(len(params)))
+		for key, value := range params {
+			buf = binary.AppendUvarint(buf, uint64(key))
+			buf = binary.AppendUvarint(buf, uint64(value))
+		}
+	}
+	if params := msg.GetAllInt(); 0 != len(params) {
+		buf = append(buf, binaryTagInt)
+		buf = binary.AppendUvarint(buf, uint64(len(params)))
+		for key, value := range params {
+			buf = binary.AppendUvarint(buf, uint64(key))
+			buf = binary.AppendVarint(buf, int64(value))
+		}
+	}
+	if params := msg.GetAllFloat(); 0 != len(params) {
+		buf = append(buf, binaryTagFloat)
+		buf = binary.AppendUvarint(buf, uint64(len(params)))
+		for key, value := range params {
+			buf = binary.AppendUvarint(buf, uint64(key))
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(value))
+		}
+	}
+	if params := msg.GetAllUIntArray(); 0 != len(params) {
+		buf = append(buf, binaryTagUIntArray)
+		buf = binary.AppendUvarint(buf, uint64(len(params)))
+		for key, array := range params {
+			buf = binary.AppendUvarint(buf, uint64(key))
+			buf = binary.AppendUvarint(buf, uint64(len(array)))
+			for _, value := range array {
+				buf = binary.AppendUvarint(buf, value)
+			}
+		}
+	}
+	if params := msg.GetAllStringArray(); 0 != len(params) {
+		buf = append(buf, binaryTagStringArray)
+		buf = binary.AppendUvarint(buf, uint64(len(params)))
+		for key, array := range params {
+			buf = binary.AppendUvarint(buf, uint64(key))
+			buf = binary.AppendUvarint(buf, uint64(len(array)))
+			for _, value := range array {
+				buf = appendString(buf, value)
+			}
+		}
+	}
+	return buf, nil
+}
+
+func appendUvarint(buf []byte, tag byte, value uint64) []byte {
+	buf = append(buf, tag)
+	return binary.AppendUvarint(buf, value)
+}
+
+func appendString(buf []byte, value string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+var errBinaryTruncated = errors.New("binary message truncated")
+
+type binaryDecoder struct {
+	data   []byte
+	offset int
+}
+
+func (decoder *binaryDecoder) decode(msg *BinaryMessage) (err error) {
+	for decoder.offset < len(decoder.data) {
+		var tag = decoder.data[decoder.offset]
+		decoder.offset++
+		switch tag {
+		case binaryTagID:
+			var value uint64
+			if value, err = decoder.readUvarint(); err != nil {
+				return
+			}
+			msg.ID = MessageID(value)
+		case binaryTagSuccess:
+			msg.Success = true
+		case binaryTagFrom:
+			var value uint64
+			if value, err = decoder.readUvarint(); err != nil {
+				return
+			}
+			msg.From = SessionID(value)
+		case binaryTagTo:
+			var value uint64
+			if value, err = decoder.readUvarint(); err != nil {
+				return
+			}
+			msg.To = SessionID(value)
+		case binaryTagTransaction:
+			var value uint64
+			if value, err = decoder.readUvarint(); err != nil {
+				return
+			}
+			msg.Transaction = TransactionID(value)
+		case binaryTagError:
+			if msg.Error, err = decoder.readString(); err != nil {
+				return
+			}
+		case binaryTagBool:
+			var count int
+			if count, err = decoder.readCount(); err != nil {
+				return
+			}
+			msg.BoolParams = make(map[ParamKey]bool, count)
+			for i := 0; i < count; i++ {
+				var key ParamKey
+				if key, err = decoder.readKey(); err != nil {
+					return
+				}
+				if decoder.offset >= len(decoder.data) {
+					return errBinaryTruncated
+				}
+				msg.BoolParams[key] = 0 != decoder.data[decoder.offset]
+				decoder.offset++
+			}
+		case binaryTagString:
+			var count int
+			if count, err = decoder.readCount(); err != nil {
+				return
+			}
+			msg.StringParams = make(map[ParamKey]string, count)
+			for i := 0; i < count; i++ {
+				var key ParamKey
+				if key, err = decoder.readKey(); err != nil {
+					return
+				}
+				if msg.StringParams[key], err = decoder.readString(); err != nil {
+					return
+				}
+			}
+		case binaryTagUInt:
+			var count int
+			if count, err = decoder.readCount(); err != nil {
+				return
+			}
+			msg.UIntParams = make(map[ParamKey]uint, count)
+			for i := 0; i < count; i++ {
+				var key ParamKey
+				var value uint64
+				if key, err = decoder.readKey(); err != nil {
+					return
+				}
+				if value, err = decoder.readUvarint(); err != nil {
+					return
+				}
+				msg.UIntParams[key] = uint(value)
+			}
+		case binaryTagInt:
+			var count int
+			if count, err = decoder.readCount(); err != nil {
+				return
+			}
+			msg.IntParams = make(map[ParamKey]int, count)
+			for i := 0; i < count; i++ {
+				var key ParamKey
+				if key, err = decoder.readKey(); err != nil {
+					return
+				}
+				value, length := binary.Varint(decoder.data[decoder.offset:])
+				if length <= 0 {
+					return errBinaryTruncated
+				}
+				decoder.offset += length
+				msg.IntParams[key] = int(value)
+			}
+		case binaryTagFloat:
+			var count int
+			if count, err = decoder.readCount(); err != nil {
+				return
+			}
+			msg.FloatParams = make(map[ParamKey]float64, count)
+			for i := 0; i < count; i++ {
+				var key ParamKey
+				if key, err = decoder.readKey(); err != nil {
+					return
+				}
+				if decoder.offset+8 > len(decoder.data) {
+					return errBinaryTruncated
+				}
+				var bits = binary.LittleEndian.Uint64(decoder.data[decoder.offset:])
+				decoder.offset += 8
+				msg.FloatParams[key] = math.Float64frombits(bits)
+			}
+		case binaryTagUIntArray:
+			var count int
+			if count, err = decoder.readCount(); err != nil {
+				return
+			}
+			msg.UIntArrayParams = make(map[ParamKey][]uint64, count)
+			for i := 0; i < count; i++ {
+				var key ParamKey
+				var length int
+				if key, err = decoder.readKey(); err != nil {
+					return
+				}
+				if length, err = decoder.readCount(); err != nil {
+					return
+				}
+				var array = make([]uint64, length)
+				for j := 0; j < length; j++ {
+					if array[j], err = decoder.readUvarint(); err != nil {
+						return
+					}
+				}
+				msg.UIntArrayParams[key] = array
+			}
+		case binaryTagStringArray:
+			var count int
+			if count, err = decoder.readCount(); err != nil {
+				return
+			}
+			msg.StringArrayParams = make(map[ParamKey][]string, count)
+			for i := 0; i < count; i++ {
+				var key ParamKey
+				var length int
+				if key, err = decoder.readKey(); err != nil {
+					return
+				}
+				if length, err = decoder.readCount(); err != nil {
+					return
+				}
+				var array = make([]string, length)
+				for j := 0; j < length; j++ {
+					if array[j], err = decoder.readString(); err != nil {
+						return
+					}
+				}
+				msg.StringArrayParams[key] = array
+			}
+		default:
+			return fmt.Errorf("invalid binary tag %d at offset %d", tag, decoder.offset-1)
+		}
+	}
+	return nil
+}
+
+func (decoder *binaryDecoder) readUvarint() (uint64, error) {
+	value, length := binary.Uvarint(decoder.data[decoder.offset:])
+	if length <= 0 {
+		return 0, errBinaryTruncated
+	}
+	decoder.offset += length
+	return value, nil
+}
+
+func (decoder *binaryDecoder) readKey() (ParamKey, error) {
+	value, err := decoder.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	if value > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid param key %d", value)
+	}
+	return ParamKey(value), nil
+}
+
+//readCount read element count, which never exceeds the remaining bytes
+func (decoder *binaryDecoder) readCount() (int, error) {
+	value, err := decoder.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	if value > uint64(len(decoder.data)-decoder.offset) {
+		return 0, errBinaryTruncated
+	}
+	return int(value), nil
+}
+
+func (decoder *binaryDecoder) readString() (string, error) {
+	length, err := decoder.readCount()
+	if err != nil {
+		return "", err
+	}
+	var value = string(decoder.data[decoder.offset : decoder.offset+length])
+	decoder.offset += length
+	return value, nil
+}
@@ -0,0 +1,156 @@
+package framework
+
+import "testing"
+
+var checkBinaryConsistency = func(t *testing.T, f func(msg *JsonMessage)) {
+	var codec = BinaryCodec{}
+	for i := 0; i < testRepeat; i++ {
+		origin, err := generateMessage()
+		if err != nil {
+			t.Fatalf("generate message fail: %s", err.Error())
+		}
+		f(origin)
+		data, err := codec.Encode(origin)
+		if err != nil {
+			t.Fatalf("encode fail: %s", err.Error())
+		}
+		decoded, err := DecodeMessage(CodecBinary, data)
+		if err != nil {
+			t.Fatalf("decode message fail: %s", err.Error())
+		}
+		result, err := isIdentical(origin, CloneJsonMessage(decoded), t)
+		if err != nil {
+			t.Fatalf("identify fail: %s", err.Error())
+		}
+		if !result {
+			t.Fatal("message corrupted")
+		}
+		t.Logf("%dth try success", i+1)
+	}
+}
+
+func Test_BinaryBaseMember(t *testing.T) {
+	var empty = func(msg *JsonMessage) {}
+	checkBinaryConsistency(t, empty)
+}
+
+func Test_BinaryBoolParam(t *testing.T) {
+	const paramCount = 5
+	var prepare = func(msg *JsonMessage) {
+		generateBoolParam(msg, paramCount)
+	}
+	checkBinaryConsistency(t, prepare)
+}
+
+func Test_BinaryStringParam(t *testing.T) {
+	const paramCount = 5
+	var prepare = func(msg *JsonMessage) {
+		generateStringParam(msg, paramCount)
+	}
+	checkBinaryConsistency(t, prepare)
+}
+
+func Test_BinaryUIntParam(t *testing.T) {
+	const paramCount = 5
+	var prepare = func(msg *JsonMessage) {
+		generateUIntParam(msg, paramCount)
+	}
+	checkBinaryConsistency(t, prepare)
+}
+
+func Test_BinaryIntParam(t *testing.T) {
+	const paramCount = 5
+	var prepare = func(msg *JsonMessage) {
+		generateIntParam(msg, paramCount)
+	}
+	checkBinaryConsistency(t, prepare)
+}
+
+func Test_BinaryFloatParam(t *testing.T) {
+	const paramCount = 5
+	var prepare = func(msg *JsonMessage) {
+		generateFloatParam(msg, paramCount)
+	}
+	checkBinaryConsistency(t, prepare)
+}
+
+func Test_BinaryUIntArrayParam(t *testing.T) {
+	const paramCount = 5
+	var prepare = func(msg *JsonMessage) {
+		generateUIntArrayParam(msg, paramCount)
+	}
+	checkBinaryConsistency(t, prepare)
+}
+
+func Test_BinaryStringArrayParam(t *testing.T) {
+	const paramCount = 5
+	var prepare = func(msg *JsonMessage) {
+		generateStringArrayParam(msg, paramCount)
+	}
+	checkBinaryConsistency(t, prepare)
+}
+
+func Test_BinaryMixedParam(t *testing.T) {
+	const paramCount = 3
+	var prepare = func(msg *JsonMessage) {
+		generateBoolParam(msg, paramCount)
+		generateUIntParam(msg, paramCount)
+		generateIntParam(msg, paramCount)
+		generateStringParam(msg, paramCount)
+		generateFloatParam(msg, paramCount)
+		generateUIntArrayParam(msg, paramCount)
+		generateStringArrayParam(msg, paramCount)
+	}
+	checkBinaryConsistency(t, prepare)
+}
+
+func Test_BinaryMessageSerialize(t *testing.T) {
+	const (
+		paramCount = 3
+	)
+	for i := 0; i < testRepeat; i++ {
+		origin, err := CreateBinaryMessage(MessageID(generator.Uint32()))
+		if err != nil {
+			t.Fatalf("create message fail: %s", err.Error())
+		}
+		origin.SetFromSession(SessionID(generator.Uint32()))
+		origin.SetToSession(SessionID(generator.Uint32()))
+		origin.SetTransactionID(TransactionID(generator.Uint32()))
+		origin.SetError("binary error")
+		generateBoolParam(origin, paramCount)
+		generateUIntParam(origin, paramCount)
+		generateIntParam(origin, paramCount)
+		generateStringParam(origin, paramCount)
+		generateFloatParam(origin, paramCount)
+		generateUIntArrayParam(origin, paramCount)
+		generateStringArrayParam(origin, paramCount)
+		data, err := origin.Serialize()
+		if err != nil {
+			t.Fatalf("serialize fail: %s", err.Error())
+		}
+		target, err := MessageFromBinary(data)
+		if err != nil {
+			t.Fatalf("parse message fail: %s", err.Error())
+		}
+		identical, err := isIdentical(CloneJsonMessage(origin), CloneJsonMessage(target), t)
+		if err != nil {
+			t.Fatalf("compare %dth message fail: %s", i, err.Error())
+		}
+		if !identical {
+			t.Fatalf("%dth message is not identical", i)
+		}
+	}
+}
+
+func Test_BinaryTruncated(t *testing.T) {
+	origin, _ := generateMessage()
+	generateStringArrayParam(origin, 3)
+	data, err := encodeBinaryMessage(origin)
+	if err != nil {
+		t.Fatalf("encode fail: %s", err.Error())
+	}
+	if _, err = MessageFromBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("truncated message not detected")
+	}
+	t.Logf("truncated message rejected: %s", err.Error())
+}
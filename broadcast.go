@@ -0,0 +1,81 @@
+package framework
+
+import (
+	"errors"
+	"sort"
+)
+
+//TargetFilter select connected services for Broadcast and SendToType, all filters must be satisfied
+type TargetFilter func(peer PeerInfo) bool
+
+//ExcludeTargets filter out services with specified names
+func ExcludeTargets(names ...string) TargetFilter {
+	return func(peer PeerInfo) bool {
+		return !containsString(names, peer.Name)
+	}
+}
+
+//RequireCapability select services advertised capability in handshake
+func RequireCapability(capability string) TargetFilter {
+	return func(peer PeerInfo) bool {
+		return peer.HasCapability(capability)
+	}
+}
+
+//Broadcast send a clone of message to all connected services, submodules excluded,
+//return result of each target selected, nil for message queued
+func (endpoint *EndpointService) Broadcast(msg Message, filters ...TargetFilter) map[string]error {
+	return endpoint.multicast(msg, func(entry connEntry) bool {
+		return true
+	}, filters)
+}
+
+//SendToType send a clone of message to connected services with specified type,
+//return result of each target selected, nil for message queued
+func (endpoint *EndpointService) SendToType(msg Message, t ServiceType, filters ...TargetFilter) map[string]error {
+	return endpoint.multicast(msg, func(entry connEntry) bool {
+		return t == entry.Type
+	}, filters)
+}
+
+func (endpoint *EndpointService) multicast(msg Message, selector func(entry connEntry) bool, filters []TargetFilter) map[string]error {
+	var results = map[string]error{}
+	var targets []connEntry
+	for _, entry := range endpoint.connections.list() {
+		if !selector(entry) || !matchFilters(entry.Peer, filters) {
+			continue
+		}
+		targets = append(targets, entry)
+	}
+	//stable order of delivery
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Name < targets[j].Name
+	})
+	for _, entry := range targets {
+		if !endpoint.isRunning() {
+			results[entry.Name] = errors.New("endpoint closed")
+			continue
+		}
+		results[entry.Name] = endpoint.enqueueClone(entry, msg)
+	}
+	return results
+}
+
+//enqueueClone so that targets never share message
+func (endpoint *EndpointService) enqueueClone(entry connEntry, msg Message) error {
+	var clone = CloneJsonMessage(msg)
+	clone.SetSender(msg.GetSender())
+	if send, err := endpoint.interceptOutbound(entry, clone); !send {
+		return err
+	}
+	return endpoint.enqueue(entry, clone)
+}
+
+func matchFilters(peer PeerInfo, filters []TargetFilter) bool {
+	for _, filter := range filters {
+		if !filter(peer) {
+			return false
+		}
+	}
+	return true
+}
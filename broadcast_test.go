@@ -0,0 +1,71 @@
+package framework
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_Broadcast(t *testing.T) {
+	const (
+		cellCount = 2
+	)
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	var cells []*memoryPeer
+	for i := 0; i < cellCount; i++ {
+		var cell = createMemoryPeer(t, network, ServiceTypeCell, byte(i+1))
+		if err := cell.Start(); err != nil {
+			t.Fatalf("start cell fail: %s", err.Error())
+		}
+		defer cell.Stop()
+		waitMemoryEvent(t, cell.EventChan, fmt.Sprintf("%s connect", cell.GetName()))
+		waitMemoryEvent(t, core.EventChan, fmt.Sprintf("%s accepted", cell.GetName()))
+		cells = append(cells, cell)
+	}
+	msg, _ := CreateJsonMessage(AddressPoolChangedEvent)
+	msg.SetString(ParamKeyName, "pool")
+	var results = core.Broadcast(msg)
+	if cellCount != len(results) {
+		t.Fatalf("%d target(s) selected", len(results))
+	}
+	for name, err := range results {
+		if err != nil {
+			t.Fatalf("broadcast to '%s' fail: %s", name, err.Error())
+		}
+	}
+	for _, cell := range cells {
+		select {
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s wait broadcast timeout", cell.GetName())
+		case received := <-cell.MessageChan:
+			if name, _ := received.GetString(ParamKeyName); "pool" != name {
+				t.Fatalf("unexpected message received by %s", cell.GetName())
+			}
+		}
+	}
+	results = core.SendToType(msg, ServiceTypeCell, ExcludeTargets(cells[0].GetName()))
+	if _, selected := results[cells[1].GetName()]; !selected || 1 != len(results) {
+		t.Fatalf("unexpected targets %v", results)
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait message timeout")
+	case <-cells[1].MessageChan:
+	}
+	if results = core.SendToType(msg, ServiceTypeImage); 0 != len(results) {
+		t.Fatalf("unexpected targets %v", results)
+	}
+	if results = core.Broadcast(msg, RequireCapability("unknown")); 0 != len(results) {
+		t.Fatalf("unexpected targets %v", results)
+	}
+	select {
+	case <-cells[0].MessageChan:
+		t.Fatal("message received by excluded target")
+	default:
+	}
+}
@@ -0,0 +1,118 @@
+package framework
+
+import (
+	"fmt"
+	"sync"
+)
+
+type CodecID uint8
+
+const (
+	CodecJson CodecID = iota
+	CodecBinary
+)
+
+const (
+	CodecNameJson   = "json"
+	CodecNameBinary = "binary"
+	//codec id must fit in the lower bits of frame flags
+	MaxCodecID = 0x0F
+)
+
+//MessageCodec convert Message between wire format
+type MessageCodec interface {
+	ID() CodecID
+	Name() string
+	Encode(msg Message) ([]byte, error)
+	Decode(data []byte) (Message, error)
+}
+
+var (
+	codecLock     sync.RWMutex
+	codecRegistry = map[CodecID]MessageCodec{}
+)
+
+func init() {
+	RegisterCodec(&JsonCodec{})
+	RegisterCodec(&BinaryCodec{})
+}
+
+func RegisterCodec(codec MessageCodec) error {
+	if codec.ID() > MaxCodecID {
+		return fmt.Errorf("codec id %d exceeds limit %d", codec.ID(), MaxCodecID)
+	}
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	if current, exists := codecRegistry[codec.ID()]; exists {
+		return fmt.Errorf("codec id %d already bound with '%s'", codec.ID(), current.Name())
+	}
+	codecRegistry[codec.ID()] = codec
+	return nil
+}
+
+func GetCodec(id CodecID) (MessageCodec, error) {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	codec, exists := codecRegistry[id]
+	if !exists {
+		return nil, fmt.Errorf("no codec registered with id %d", id)
+	}
+	return codec, nil
+}
+
+func DecodeMessage(id CodecID, data []byte) (Message, error) {
+	codec, err := GetCodec(id)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(data)
+}
+
+type JsonCodec struct {
+}
+
+func (codec *JsonCodec) ID() CodecID {
+	return CodecJson
+}
+
+func (codec *JsonCodec) Name() string {
+	return CodecNameJson
+}
+
+func (codec *JsonCodec) Encode(msg Message) ([]byte, error) {
+	if jsonMessage, ok := msg.(*JsonMessage); ok {
+		return jsonMessage.Serialize()
+	}
+	return CloneJsonMessage(msg).Serialize()
+}
+
+func (codec *JsonCodec) Decode(data []byte) (Message, error) {
+	msg, err := MessageFromJson(data)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+type BinaryCodec struct {
+}
+
+func (codec *BinaryCodec) ID() CodecID {
+	return CodecBinary
+}
+
+func (codec *BinaryCodec) Name() string {
+	return CodecNameBinary
+}
+
+func (codec *BinaryCodec) Encode(msg Message) ([]byte, error) {
+	return encodeBinaryMessage(msg)
+}
+
+func (codec *BinaryCodec) Decode(data []byte) (Message, error) {
+	msg, err := MessageFromBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,86 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ConnectionStatusConnected    = "connected"
+	ConnectionStatusLost         = "lost"
+	ConnectionStatusDisconnected = "disconnected"
+)
+
+//ConnectionSnapshot status of a connected service when captured, never updated
+type ConnectionSnapshot struct {
+	Name             string
+	Type             ServiceType
+	Address          string //host:port of remote service
+	Status           string
+	Ready            bool
+	Established      time.Time
+	LastHeartBeat    time.Time
+	Peer             PeerInfo
+	MessagesSent     uint64
+	MessagesReceived uint64
+	BytesSent        uint64
+	BytesReceived    uint64
+	Queue            SendStatistic
+	Latency          LatencyStatistic
+}
+
+//Age of session when captured
+func (snapshot ConnectionSnapshot) Age(captured time.Time) time.Duration {
+	return captured.Sub(snapshot.Established)
+}
+
+func (status connectionStatus) String() string {
+	switch status {
+	case connStatusConnected, connStatusReady:
+		return ConnectionStatusConnected
+	case connStatusLost:
+		return ConnectionStatusLost
+	default:
+		return ConnectionStatusDisconnected
+	}
+}
+
+func (entry connEntry) snapshot() ConnectionSnapshot {
+	var snapshot = ConnectionSnapshot{Name: entry.Name, Type: entry.Type, Address: entry.Address,
+		Status: entry.Status.String(), Ready: entry.Ready, Established: entry.Established,
+		LastHeartBeat: entry.LastHeartBeat, Peer: entry.Peer}
+	snapshot.Peer.Capabilities = append([]string(nil), entry.Peer.Capabilities...)
+	var traffic = entry.Session.traffic
+	snapshot.MessagesSent = atomic.LoadUint64(&traffic.messagesSent)
+	snapshot.MessagesReceived = atomic.LoadUint64(&traffic.messagesReceived)
+	snapshot.BytesSent = atomic.LoadUint64(&traffic.bytesSent)
+	snapshot.BytesReceived = atomic.LoadUint64(&traffic.bytesReceived)
+	snapshot.Queue = entry.sendStatistic()
+	snapshot.Latency = entry.Latency.get()
+	return snapshot
+}
+
+//ListConnections return snapshots of all connected services, sorted by name
+func (endpoint *EndpointService) ListConnections() []ConnectionSnapshot {
+	var entries = endpoint.connections.list()
+	var snapshots = make([]ConnectionSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		snapshots = append(snapshots, entry.snapshot())
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots
+}
+
+//GetConnection return snapshot of a connected service
+func (endpoint *EndpointService) GetConnection(name string) (snapshot ConnectionSnapshot, err error) {
+	entry, exists := endpoint.connections.get(name)
+	if !exists {
+		err = fmt.Errorf("invalid service '%s'", name)
+		return
+	}
+	return entry.snapshot(), nil
+}
@@ -0,0 +1,43 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ConnectionSnapshot(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	core, peer := startMemoryPair(t, network)
+	var before = time.Now()
+	msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+	if err := core.SendMessage(msg, peer.GetName()); err != nil {
+		t.Fatalf("send message fail: %s", err.Error())
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait message timeout")
+	case <-peer.MessageChan:
+	}
+	snapshot, err := core.GetConnection(peer.GetName())
+	if err != nil {
+		t.Fatalf("get connection fail: %s", err.Error())
+	}
+	if ServiceTypeCell != snapshot.Type || ConnectionStatusConnected != snapshot.Status || "" == snapshot.Address {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	//handshake and message
+	if snapshot.MessagesSent < 2 || snapshot.MessagesReceived < 1 || 0 == snapshot.BytesSent || 0 == snapshot.BytesReceived {
+		t.Fatalf("unexpected traffic %d/%d messages, %d/%d bytes", snapshot.MessagesSent, snapshot.MessagesReceived,
+			snapshot.BytesSent, snapshot.BytesReceived)
+	}
+	if snapshot.Established.After(before) || snapshot.Age(time.Now()) <= 0 {
+		t.Fatalf("unexpected established time %s", snapshot.Established)
+	}
+	var connections = peer.ListConnections()
+	if 1 != len(connections) || core.GetName() != connections[0].Name {
+		t.Fatalf("unexpected connections %+v", connections)
+	}
+	if _, err = core.GetConnection("invalid"); err == nil {
+		t.Fatal("snapshot of invalid service returned")
+	}
+}
@@ -0,0 +1,112 @@
+package framework
+
+import (
+	"fmt"
+	"sync"
+)
+
+//connectionTable shared between guardian routine and senders,
+//entries only modified by guardian routine, lookup by any goroutine
+type connectionTable struct {
+	lock        sync.RWMutex
+	connections map[string]connEntry
+	submodules  map[string]chan Message
+	reasons     map[string]error
+}
+
+func newConnectionTable() *connectionTable {
+	return &connectionTable{connections: map[string]connEntry{}, submodules: map[string]chan Message{},
+		reasons: map[string]error{}}
+}
+
+//reset clear all connections when endpoint started
+func (table *connectionTable) reset() {
+	table.lock.Lock()
+	table.connections = map[string]connEntry{}
+	table.lock.Unlock()
+}
+
+func (table *connectionTable) get(name string) (entry connEntry, exists bool) {
+	table.lock.RLock()
+	entry, exists = table.connections[name]
+	table.lock.RUnlock()
+	return
+}
+
+//add return false when name already exists
+func (table *connectionTable) add(entry connEntry) bool {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	if _, exists := table.connections[entry.Name]; exists {
+		return false
+	}
+	table.connections[entry.Name] = entry
+	return true
+}
+
+func (table *connectionTable) remove(name string) (entry connEntry, exists bool) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	if entry, exists = table.connections[name]; exists {
+		delete(table.connections, name)
+	}
+	return
+}
+
+//update modify entry in place, return false when name not exists
+func (table *connectionTable) update(name string, modify func(entry *connEntry)) bool {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	entry, exists := table.connections[name]
+	if !exists {
+		return false
+	}
+	modify(&entry)
+	table.connections[name] = entry
+	return true
+}
+
+//list return a snapshot of all connections
+func (table *connectionTable) list() (entries []connEntry) {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	entries = make([]connEntry, 0, len(table.connections))
+	for _, entry := range table.connections {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (table *connectionTable) addSubmodule(name string, channel chan Message) error {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	if _, exists := table.submodules[name]; exists {
+		return fmt.Errorf("submodule '%s' already exists", name)
+	}
+	table.submodules[name] = channel
+	return nil
+}
+
+func (table *connectionTable) getSubmodule(name string) (channel chan Message, exists bool) {
+	table.lock.RLock()
+	channel, exists = table.submodules[name]
+	table.lock.RUnlock()
+	return
+}
+
+//setReason save cause of last disconnection, nil for graceful
+func (table *connectionTable) setReason(name string, reason error) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	if nil == reason {
+		delete(table.reasons, name)
+	} else {
+		table.reasons[name] = reason
+	}
+}
+
+func (table *connectionTable) getReason(name string) error {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	return table.reasons[name]
+}
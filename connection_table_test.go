@@ -0,0 +1,45 @@
+package framework
+
+import (
+	"sync"
+	"testing"
+)
+
+//run with -race
+func Test_ConcurrentSend(t *testing.T) {
+	const (
+		senderCount  = 4
+		messageCount = 200
+	)
+	var network = CreateMemoryNetwork()
+	core, peer := startMemoryPair(t, network)
+	go func() {
+		for range peer.MessageChan {
+		}
+	}()
+	var group sync.WaitGroup
+	for i := 0; i < senderCount; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for j := 0; j < messageCount; j++ {
+				msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+				if err := core.SendMessage(msg, peer.GetName()); err != nil {
+					t.Errorf("send message fail: %s", err.Error())
+					return
+				}
+				core.GetPeerInfo(peer.GetName())
+			}
+		}()
+	}
+	//connection table modified while sending
+	var another = createMemoryPeer(t, network, ServiceTypeCell, 2)
+	if err := another.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	waitMemoryEvent(t, another.EventChan, "peer connect")
+	if err := another.Stop(); err != nil {
+		t.Fatalf("stop peer fail: %s", err.Error())
+	}
+	group.Wait()
+}
@@ -0,0 +1,99 @@
+package framework
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"github.com/xtaci/kcp-go"
+	"golang.org/x/crypto/pbkdf2"
+	"sync"
+)
+
+type CryptMethod string
+
+const (
+	CryptNone      = CryptMethod("none")
+	CryptAES       = CryptMethod("aes")
+	CryptAES128    = CryptMethod("aes-128")
+	CryptAES192    = CryptMethod("aes-192")
+	CryptSalsa20   = CryptMethod("salsa20")
+	CryptSM4       = CryptMethod("sm4")
+	CryptTwofish   = CryptMethod("twofish")
+	CryptTripleDES = CryptMethod("3des")
+	CryptCast5     = CryptMethod("cast5")
+	CryptBlowfish  = CryptMethod("blowfish")
+	CryptXTEA      = CryptMethod("xtea")
+)
+
+const (
+	cryptKeyIteration = 4096
+	cryptKeyLength    = 32
+	cryptSaltPrefix   = "nano-framework:"
+)
+
+//deriveCryptKey derive key from secret with PBKDF2, salted by domain so that secret reused in domains get different key
+func deriveCryptKey(secret, domain string) []byte {
+	return pbkdf2.Key([]byte(secret), []byte(cryptSaltPrefix+domain), cryptKeyIteration, cryptKeyLength, sha1.New)
+}
+
+func createBlockCrypt(method CryptMethod, secret, domain string) (kcp.BlockCrypt, error) {
+	if "" == secret {
+		return nil, fmt.Errorf("empty secret for crypt method '%s'", method)
+	}
+	return newBlockCrypt(method, deriveCryptKey(secret, domain))
+}
+
+//newBlockCrypt create crypt with derived key, each session should have its own crypt
+func newBlockCrypt(method CryptMethod, key []byte) (kcp.BlockCrypt, error) {
+	switch method {
+	case CryptNone:
+		return kcp.NewNoneBlockCrypt(key)
+	case CryptAES:
+		return kcp.NewAESBlockCrypt(key)
+	case CryptAES128:
+		return kcp.NewAESBlockCrypt(key[:16])
+	case CryptAES192:
+		return kcp.NewAESBlockCrypt(key[:24])
+	case CryptSalsa20:
+		return kcp.NewSalsa20BlockCrypt(key)
+	case CryptSM4:
+		return kcp.NewSM4BlockCrypt(key[:16])
+	case CryptTwofish:
+		return kcp.NewTwofishBlockCrypt(key)
+	case CryptTripleDES:
+		return kcp.NewTripleDESBlockCrypt(key[:24])
+	case CryptCast5:
+		return kcp.NewCast5BlockCrypt(key[:16])
+	case CryptBlowfish:
+		return kcp.NewBlowfishBlockCrypt(key)
+	case CryptXTEA:
+		return kcp.NewXTEABlockCrypt(key[:16])
+	default:
+		return nil, fmt.Errorf("unsupported crypt method '%s'", method)
+	}
+}
+
+//lockedBlockCrypt serialize crypt shared by sessions, kcp-go pass the crypt of listener to all sessions accepted,
+//and block crypt encrypt or decrypt with an internal buffer
+type lockedBlockCrypt struct {
+	lock  sync.Mutex
+	crypt kcp.BlockCrypt
+}
+
+func newLockedBlockCrypt(crypt kcp.BlockCrypt) kcp.BlockCrypt {
+	if nil == crypt {
+		return nil
+	}
+	return &lockedBlockCrypt{crypt: crypt}
+}
+
+func (locked *lockedBlockCrypt) Encrypt(dst, src []byte) {
+	locked.lock.Lock()
+	defer locked.lock.Unlock()
+	locked.crypt.Encrypt(dst, src)
+}
+
+func (locked *lockedBlockCrypt) Decrypt(dst, src []byte) {
+	locked.lock.Lock()
+	defer locked.lock.Unlock()
+	locked.crypt.Decrypt(dst, src)
+}
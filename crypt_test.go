@@ -0,0 +1,195 @@
+package framework
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_EncryptedSession(t *testing.T) {
+	const (
+		domain = "nano"
+		secret = "session secret"
+	)
+	var methods = []CryptMethod{CryptAES, CryptAES128, CryptAES192, CryptSalsa20, CryptSM4, CryptTwofish,
+		CryptTripleDES, CryptCast5, CryptBlowfish, CryptXTEA}
+	for _, method := range methods {
+		//crypt not shared between sessions
+		serverCrypt, err := createBlockCrypt(method, secret, domain)
+		if err != nil {
+			t.Fatalf("create crypt '%s' fail: %s", method, err.Error())
+		}
+		clientCrypt, _ := createBlockCrypt(method, secret, domain)
+		client, server := createSessionPair(t, serverCrypt, clientCrypt)
+		var receiver = newFrameConn(server)
+		var sender = newFrameConn(client)
+		if _, err = receiver.ReadHandshake(); err != nil {
+			t.Fatalf("read handshake with '%s' fail: %s", method, err.Error())
+		}
+		receiver.EnableFraming()
+		origin, _ := CreateJsonMessage(ConnectionOpenedEvent)
+		origin.SetString(ParamKeySecret, secret)
+		if err = sender.WriteMessage(origin); err != nil {
+			t.Fatalf("write message with '%s' fail: %s", method, err.Error())
+		}
+		received, err := receiver.ReadMessage()
+		if err != nil {
+			t.Fatalf("read message with '%s' fail: %s", method, err.Error())
+		}
+		if value, _ := received.GetString(ParamKeySecret); value != secret {
+			t.Fatalf("message corrupted with '%s'", method)
+		}
+		t.Logf("session encrypted with '%s'", method)
+	}
+}
+
+func Test_CryptKeyDerivation(t *testing.T) {
+	var key1 = deriveCryptKey("secret", "domain1")
+	var key2 = deriveCryptKey("secret", "domain2")
+	if string(key1) == string(key2) {
+		t.Fatal("same key derived for different domain")
+	}
+	if _, err := createBlockCrypt(CryptAES, "", "domain1"); err == nil {
+		t.Fatal("empty secret accepted")
+	}
+	if _, err := createBlockCrypt("rot13", "secret", "domain1"); err == nil {
+		t.Fatal("invalid method accepted")
+	}
+}
+
+func Test_SharedBlockCrypt(t *testing.T) {
+	const (
+		routines = 8
+		rounds   = 500
+	)
+	origin, err := createBlockCrypt(CryptAES, "shared secret", "nano")
+	if err != nil {
+		t.Fatalf("create crypt fail: %s", err.Error())
+	}
+	//crypt of listener used by all sessions accepted
+	var shared = newLockedBlockCrypt(origin)
+	var group sync.WaitGroup
+	var errChan = make(chan error, routines)
+	for index := 0; index < routines; index++ {
+		group.Add(1)
+		go func(index int) {
+			defer group.Done()
+			var plain = []byte(fmt.Sprintf("plain text of session %02d", index))
+			var cipher = make([]byte, len(plain))
+			var decrypted = make([]byte, len(plain))
+			for round := 0; round < rounds; round++ {
+				shared.Encrypt(cipher, plain)
+				shared.Decrypt(decrypted, cipher)
+				if string(decrypted) != string(plain) {
+					errChan <- fmt.Errorf("session %d corrupted at round %d", index, round)
+					return
+				}
+			}
+		}(index)
+	}
+	group.Wait()
+	close(errChan)
+	for err = range errChan {
+		t.Fatal(err)
+	}
+	if nil != newLockedBlockCrypt(nil) {
+		t.Fatal("nil crypt wrapped")
+	}
+}
+
+func createEncryptedPeer(t *testing.T, serviceType ServiceType, index byte, options ...EndpointOption) *memoryPeer {
+	const (
+		secret = "endpoint secret"
+	)
+	var endpoint EndpointService
+	var err error
+	options = append([]EndpointOption{WithEncryption(CryptAES, secret)}, options...)
+	if ServiceTypeCore == serviceType {
+		endpoint, err = CreateStubEndpoint("", 0, "test", "127.0.0.1", options...)
+	} else {
+		endpoint, err = CreatePeerEndpoint("", 0, "test", options...)
+	}
+	if err != nil {
+		t.Fatalf("create endpoint fail: %s", err.Error())
+	}
+	var peer = &memoryPeer{endpoint, make(chan bool, 8), make(chan Message, 64)}
+	peer.handler = peer
+	var inf = net.Interface{HardwareAddr: net.HardwareAddr{0, 0, 0, 0, 0, index}}
+	if err = peer.GenerateName(serviceType, &inf); err != nil {
+		t.Fatal(err)
+	}
+	return peer
+}
+
+func Test_EncryptedEndpoint(t *testing.T) {
+	const (
+		peerCount    = 2
+		messageCount = 200
+	)
+	var core = createEncryptedPeer(t, ServiceTypeCore, 0, WithStubPublisher(&memoryPublisher{network: CreateMemoryNetwork()}))
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	var stub = fmt.Sprintf("127.0.0.1:%d", core.GetListenPort())
+	var peers []*memoryPeer
+	for i := 0; i < peerCount; i++ {
+		var peer = createEncryptedPeer(t, ServiceTypeCell, byte(i+1), WithStaticStubs(stub))
+		if err := peer.Start(); err != nil {
+			t.Fatalf("start peer fail: %s", err.Error())
+		}
+		defer peer.Stop()
+		waitMemoryEvent(t, peer.EventChan, fmt.Sprintf("%s connect", peer.GetName()))
+		waitMemoryEvent(t, core.EventChan, fmt.Sprintf("%s accepted", peer.GetName()))
+		peers = append(peers, peer)
+	}
+	//sessions accepted by the same listener send and receive concurrently
+	var group sync.WaitGroup
+	for _, peer := range peers {
+		group.Add(2)
+		go func(target string) {
+			defer group.Done()
+			for i := 0; i < messageCount; i++ {
+				msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+				if err := core.SendMessage(msg, target); err != nil {
+					t.Errorf("send to %s fail: %s", target, err.Error())
+					return
+				}
+			}
+		}(peer.GetName())
+		go func(sender *memoryPeer) {
+			defer group.Done()
+			for i := 0; i < messageCount; i++ {
+				msg, _ := CreateJsonMessage(AddressPoolChangedEvent)
+				if err := sender.SendMessage(msg, core.GetName()); err != nil {
+					t.Errorf("send from %s fail: %s", sender.GetName(), err.Error())
+					return
+				}
+			}
+		}(peer)
+	}
+	var receive = func(receiver *memoryPeer, count int) {
+		for i := 0; i < count; i++ {
+			select {
+			case <-time.After(5 * time.Second):
+				t.Errorf("%s receive message timeout", receiver.GetName())
+				return
+			case <-receiver.MessageChan:
+			}
+		}
+	}
+	group.Add(peerCount + 1)
+	go func() {
+		defer group.Done()
+		receive(core, messageCount*peerCount)
+	}()
+	for _, peer := range peers {
+		go func(receiver *memoryPeer) {
+			defer group.Done()
+			receive(receiver, messageCount)
+		}(peer)
+	}
+	group.Wait()
+}
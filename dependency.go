@@ -0,0 +1,139 @@
+package framework
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+//dependencyState service types required before local service prepared
+type dependencyState struct {
+	lock      sync.Mutex
+	required  []ServiceType
+	satisfied bool
+}
+
+func newDependencyState() *dependencyState {
+	return &dependencyState{}
+}
+
+func (state *dependencyState) add(dependencies []ServiceType) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	for _, dependency := range dependencies {
+		var exists = false
+		for _, required := range state.required {
+			if required == dependency {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			state.required = append(state.required, dependency)
+		}
+	}
+}
+
+//evaluate return current status and whether changed, never satisfied without any dependency
+func (state *dependencyState) evaluate(connected map[ServiceType]bool) (satisfied, changed bool) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if 0 == len(state.required) {
+		return false, false
+	}
+	satisfied = true
+	for _, required := range state.required {
+		if !connected[required] {
+			satisfied = false
+			break
+		}
+	}
+	changed = satisfied != state.satisfied
+	state.satisfied = satisfied
+	return
+}
+
+func (state *dependencyState) reset() {
+	state.lock.Lock()
+	state.satisfied = false
+	state.lock.Unlock()
+}
+
+//AddDependency declare service types required, OnDependencyReady invoked when all of them connected,
+//must be called before endpoint started
+func (endpoint *EndpointService) AddDependency(dependencies []ServiceType) {
+	endpoint.dependencies.add(dependencies)
+}
+
+//SetServiceReady notify all connected services that local service ready,
+//reset to not ready when any dependency lost
+func (endpoint *EndpointService) SetServiceReady() {
+	atomic.StoreInt32(&endpoint.serviceReady, 1)
+	for _, entry := range endpoint.connections.list() {
+		endpoint.notifyServiceReady(entry.Name, true)
+	}
+}
+
+//IsServiceReady return true after SetServiceReady called and no dependency lost
+func (endpoint *EndpointService) IsServiceReady() bool {
+	return 1 == atomic.LoadInt32(&endpoint.serviceReady)
+}
+
+//IsPeerReady return true when connected service declared ready
+func (endpoint *EndpointService) IsPeerReady(name string) bool {
+	entry, exists := endpoint.connections.get(name)
+	return exists && entry.Ready
+}
+
+func (endpoint *EndpointService) notifyServiceReady(target string, ready bool) {
+	msg, err := CreateJsonMessage(ServiceReadyEvent)
+	if err != nil {
+		log.Printf("<endpoint> create message fail:%s", err.Error())
+		return
+	}
+	msg.SetString(ParamKeyName, endpoint.name)
+	msg.SetUInt(ParamKeyType, uint(endpoint.serviceType))
+	msg.SetBoolean(ParamKeyFlag, ready)
+	if err = endpoint.TrySend(msg, target); err != nil {
+		log.Printf("<endpoint> warning: notify ready status to '%s' fail: %s", target, err.Error())
+	}
+}
+
+//checkDependency invoked in main routine when any service connected or disconnected
+func (endpoint *EndpointService) checkDependency() {
+	var connected = map[ServiceType]bool{}
+	for _, entry := range endpoint.connections.list() {
+		connected[entry.Type] = true
+	}
+	satisfied, changed := endpoint.dependencies.evaluate(connected)
+	if !changed {
+		return
+	}
+	if satisfied {
+		log.Printf("<endpoint> all dependencies of %s connected", endpoint.name)
+		endpoint.handler.OnDependencyReady()
+		return
+	}
+	log.Printf("<endpoint> dependency of %s lost", endpoint.name)
+	if atomic.CompareAndSwapInt32(&endpoint.serviceReady, 1, 0) {
+		for _, entry := range endpoint.connections.list() {
+			endpoint.notifyServiceReady(entry.Name, false)
+		}
+	}
+}
+
+//handleReadyEvent save ready status declared by remote service
+func (endpoint *EndpointService) handleReadyEvent(msg Message) {
+	var ready = true
+	if flag, err := msg.GetBoolean(ParamKeyFlag); err == nil {
+		ready = flag
+	}
+	var name = msg.GetSender()
+	if !endpoint.connections.update(name, func(entry *connEntry) {
+		entry.Ready = ready
+	}) {
+		log.Printf("<endpoint> invalid service '%s' for ready event", name)
+		return
+	}
+	log.Printf("<endpoint> service '%s' ready status: %t", name, ready)
+}
@@ -0,0 +1,91 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+type dependentPeer struct {
+	*memoryPeer
+	ReadyChan chan bool
+}
+
+func (peer *dependentPeer) OnDependencyReady() {
+	peer.SetServiceReady()
+	peer.ReadyChan <- true
+}
+
+func waitCondition(t *testing.T, condition func() bool, description string) {
+	var deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("wait %s timeout", description)
+}
+
+func Test_DependencyState(t *testing.T) {
+	var state = newDependencyState()
+	if _, changed := state.evaluate(map[ServiceType]bool{}); changed {
+		t.Fatal("changed without dependency")
+	}
+	state.add([]ServiceType{ServiceTypeCore, ServiceTypeImage, ServiceTypeCore})
+	if 2 != len(state.required) {
+		t.Fatalf("unexpected dependencies %v", state.required)
+	}
+	if satisfied, changed := state.evaluate(map[ServiceType]bool{ServiceTypeCore: true}); satisfied || changed {
+		t.Fatal("satisfied with partial dependencies")
+	}
+	var connected = map[ServiceType]bool{ServiceTypeCore: true, ServiceTypeImage: true}
+	if satisfied, changed := state.evaluate(connected); !satisfied || !changed {
+		t.Fatal("not satisfied with all dependencies")
+	}
+	if _, changed := state.evaluate(connected); changed {
+		t.Fatal("satisfied twice")
+	}
+	if satisfied, changed := state.evaluate(map[ServiceType]bool{ServiceTypeImage: true}); satisfied || !changed {
+		t.Fatal("still satisfied when dependency lost")
+	}
+}
+
+func Test_ServiceReady(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	var cell = &dependentPeer{createMemoryPeer(t, network, ServiceTypeCell, 1,
+		ReconnectPolicy{InitialDelay: 50 * time.Millisecond, Multiplier: 1, MaxDelay: 50 * time.Millisecond}),
+		make(chan bool, 8)}
+	cell.handler = cell
+	cell.AddDependency([]ServiceType{ServiceTypeCore})
+	if err := cell.Start(); err != nil {
+		t.Fatalf("start cell fail: %s", err.Error())
+	}
+	defer cell.Stop()
+	waitMemoryEvent(t, cell.ReadyChan, "dependency ready")
+	if !cell.IsServiceReady() {
+		t.Fatal("cell not ready")
+	}
+	waitCondition(t, func() bool {
+		return core.IsPeerReady(cell.GetName())
+	}, "cell ready")
+	if err := core.Stop(); err != nil {
+		t.Fatalf("stop core fail: %s", err.Error())
+	}
+	waitCondition(t, func() bool {
+		return !cell.IsServiceReady()
+	}, "dependency lost")
+	//core restarted
+	core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("restart core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	waitMemoryEvent(t, cell.ReadyChan, "dependency recovered")
+	waitCondition(t, func() bool {
+		return core.IsPeerReady(cell.GetName())
+	}, "cell ready again")
+}
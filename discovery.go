@@ -0,0 +1,203 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/project-nano/sonar"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ServiceLocation of a published stub
+type ServiceLocation struct {
+	Type     string
+	Protocol string
+	Address  string
+	Port     int
+	Priority int //lower preferred
+}
+
+//StubPublisher announce stub service to peers
+type StubPublisher interface {
+	Publish(service ServiceLocation) error
+	Start() error
+	Stop() error
+}
+
+//StubLocator find stub services for peer endpoint
+type StubLocator interface {
+	//Locate return stubs available and the local address reachable by them
+	Locate(timeout time.Duration) (localAddress string, services []ServiceLocation, err error)
+}
+
+const (
+	//priority attached to protocol in multicast echo, ignored by endpoint before multiple stubs
+	sonarPrioritySeparator = ";priority="
+	//wait other stubs after first echo received
+	sonarEchoWindow = 500 * time.Millisecond
+)
+
+//WithStubPublisher replace multicast publisher of stub endpoint
+func WithStubPublisher(publisher StubPublisher) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		endpoint.publisher = publisher
+		return nil
+	})
+}
+
+//WithStubLocator replace multicast query of peer endpoint
+func WithStubLocator(locator StubLocator) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		endpoint.locator = locator
+		return nil
+	})
+}
+
+//WithStubPriority advertise priority of stub, peers prefer stub with lower priority, 0 by default
+func WithStubPriority(priority int) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		if priority < 0 {
+			return fmt.Errorf("invalid priority %d", priority)
+		}
+		endpoint.stubPriority = priority
+		return nil
+	})
+}
+
+type sonarPublisher struct {
+	listener *sonar.Listener
+}
+
+func createSonarPublisher(groupAddress string, groupPort int, domain, listenAddress string) (publisher *sonarPublisher, err error) {
+	listenInterface, err := getInterfaceByAddress(listenAddress)
+	if err != nil {
+		return
+	}
+	listener, err := sonar.CreateListener(groupAddress, groupPort, domain, listenInterface)
+	if err != nil {
+		return
+	}
+	return &sonarPublisher{listener}, nil
+}
+
+func (publisher *sonarPublisher) Publish(service ServiceLocation) error {
+	var protocol = service.Protocol
+	if 0 != service.Priority {
+		protocol = fmt.Sprintf("%s%s%d", protocol, sonarPrioritySeparator, service.Priority)
+	}
+	return publisher.listener.AddService(service.Type, protocol, service.Address, service.Port)
+}
+
+func (publisher *sonarPublisher) Start() error {
+	return publisher.listener.Start()
+}
+
+func (publisher *sonarPublisher) Stop() error {
+	return publisher.listener.Stop()
+}
+
+//sonarLocator collect echoes from all stubs in multicast group
+type sonarLocator struct {
+	domain        string
+	querySender   *net.UDPConn
+	echoReceiver  *net.UDPConn
+	remoteAddress *net.UDPAddr
+	random        *rand.Rand
+}
+
+func createSonarLocator(groupAddress string, groupPort int, domain string) (locator *sonarLocator, err error) {
+	//same as sonar.Pinger, echo sent to port before group port
+	listenAddress, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", groupAddress, groupPort-1))
+	if err != nil {
+		return
+	}
+	remoteAddress, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", groupAddress, groupPort))
+	if err != nil {
+		return
+	}
+	receiver, err := net.ListenMulticastUDP("udp", nil, listenAddress)
+	if err != nil {
+		return
+	}
+	sender, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		receiver.Close()
+		return
+	}
+	return &sonarLocator{domain, sender, receiver, remoteAddress,
+		rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+}
+
+func (locator *sonarLocator) Locate(timeout time.Duration) (localAddress string, services []ServiceLocation, err error) {
+	const (
+		maxPingID = 0xffff
+	)
+	var pingID = locator.random.Intn(maxPingID)
+	packet, err := json.Marshal(sonar.Message{Type: sonar.SonarPing, Domain: locator.domain, ID: pingID})
+	if err != nil {
+		return
+	}
+	if _, err = locator.querySender.WriteToUDP(packet, locator.remoteAddress); err != nil {
+		return
+	}
+	var deadline = time.Now().Add(timeout)
+	var buffer = make([]byte, sonar.DefaultBufferSize)
+	var echoReceived = false
+	for {
+		if err = locator.echoReceiver.SetReadDeadline(deadline); err != nil {
+			return
+		}
+		count, _, readError := locator.echoReceiver.ReadFromUDP(buffer)
+		if readError != nil {
+			if netError, isNetError := readError.(net.Error); isNetError && netError.Timeout() {
+				break
+			}
+			return "", nil, readError
+		}
+		var echo sonar.Message
+		if err = json.Unmarshal(buffer[:count], &echo); err != nil {
+			continue
+		}
+		if sonar.SonarEcho != echo.Type || pingID != echo.ID {
+			continue
+		}
+		localAddress = echo.Requestor
+		for _, service := range echo.Services {
+			var location = parseSonarService(service)
+			if !containsLocation(services, location) {
+				services = append(services, location)
+			}
+		}
+		if !echoReceived {
+			echoReceived = true
+			if windowEnd := time.Now().Add(sonarEchoWindow); windowEnd.Before(deadline) {
+				deadline = windowEnd
+			}
+		}
+	}
+	if !echoReceived {
+		return "", nil, fmt.Errorf("query timeout")
+	}
+	return localAddress, services, nil
+}
+
+func parseSonarService(service sonar.Service) ServiceLocation {
+	var location = ServiceLocation{service.Type, service.Protocol, service.Address, service.Port, 0}
+	if index := strings.Index(service.Protocol, sonarPrioritySeparator); index >= 0 {
+		location.Protocol = service.Protocol[:index]
+		location.Priority, _ = strconv.Atoi(service.Protocol[index+len(sonarPrioritySeparator):])
+	}
+	return location
+}
+
+func containsLocation(services []ServiceLocation, target ServiceLocation) bool {
+	for _, service := range services {
+		if service == target {
+			return true
+		}
+	}
+	return false
+}
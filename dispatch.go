@@ -0,0 +1,188 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+//MessageHandleFunc process message dispatched by id or resource
+type MessageHandleFunc func(msg Message)
+
+//DispatchOption of registered message handler
+type DispatchOption func(entry *dispatchEntry) error
+
+const (
+	messageFieldMask = 0xFF
+)
+
+//GetMessageType return request, response or event
+func GetMessageType(id MessageID) uint {
+	return uint(id & messageFieldMask)
+}
+
+func GetMessageResource(id MessageID) uint {
+	return uint(id >> ResourceOffset & messageFieldMask)
+}
+
+func GetMessageOperate(id MessageID) uint {
+	return uint(id >> OperateOffset)
+}
+
+//WithHandlerWorker invoke handler in a dedicated goroutine, so that slow handler never blocks main routine,
+//messages of the same handler still processed in order, main routine waits when queue full
+func WithHandlerWorker(queueSize int) DispatchOption {
+	return func(entry *dispatchEntry) error {
+		if queueSize <= 0 {
+			return fmt.Errorf("invalid queue size %d", queueSize)
+		}
+		entry.queue = make(chan Message, queueSize)
+		return nil
+	}
+}
+
+type dispatchEntry struct {
+	handle MessageHandleFunc
+	queue  chan Message //nil for invoked in main routine
+}
+
+func (entry *dispatchEntry) deliver(msg Message, stop chan bool) {
+	if nil == entry.queue {
+		entry.handle(msg)
+		return
+	}
+	select {
+	case entry.queue <- msg:
+	case <-stop:
+		//worker stopped
+	}
+}
+
+func (entry *dispatchEntry) workerRoutine(stop chan bool) {
+	for {
+		select {
+		case <-stop:
+			return
+		case msg := <-entry.queue:
+			entry.handle(msg)
+		}
+	}
+}
+
+//messageDispatcher select handler by message id first, then resource
+type messageDispatcher struct {
+	lock      sync.RWMutex
+	messages  map[MessageID]*dispatchEntry
+	resources map[uint]*dispatchEntry
+	stop      chan bool //nil when workers not running
+}
+
+func newMessageDispatcher() *messageDispatcher {
+	return &messageDispatcher{messages: map[MessageID]*dispatchEntry{}, resources: map[uint]*dispatchEntry{}}
+}
+
+func createDispatchEntry(handle MessageHandleFunc, options []DispatchOption) (entry *dispatchEntry, err error) {
+	if nil == handle {
+		err = errors.New("nil handler")
+		return
+	}
+	entry = &dispatchEntry{handle: handle}
+	for _, option := range options {
+		if err = option(entry); err != nil {
+			return
+		}
+	}
+	return entry, nil
+}
+
+func (dispatcher *messageDispatcher) addMessage(id MessageID, entry *dispatchEntry) error {
+	dispatcher.lock.Lock()
+	defer dispatcher.lock.Unlock()
+	if _, exists := dispatcher.messages[id]; exists {
+		return fmt.Errorf("handler of message %08X already registered", uint32(id))
+	}
+	dispatcher.messages[id] = entry
+	dispatcher.startWorker(entry)
+	return nil
+}
+
+func (dispatcher *messageDispatcher) addResource(resource uint, entry *dispatchEntry) error {
+	dispatcher.lock.Lock()
+	defer dispatcher.lock.Unlock()
+	if _, exists := dispatcher.resources[resource]; exists {
+		return fmt.Errorf("handler of resource %d already registered", resource)
+	}
+	dispatcher.resources[resource] = entry
+	dispatcher.startWorker(entry)
+	return nil
+}
+
+//startWorker must call with lock held
+func (dispatcher *messageDispatcher) startWorker(entry *dispatchEntry) {
+	if nil != entry.queue && nil != dispatcher.stop {
+		go entry.workerRoutine(dispatcher.stop)
+	}
+}
+
+func (dispatcher *messageDispatcher) start() {
+	dispatcher.lock.Lock()
+	defer dispatcher.lock.Unlock()
+	dispatcher.stop = make(chan bool)
+	for _, entry := range dispatcher.messages {
+		dispatcher.startWorker(entry)
+	}
+	for _, entry := range dispatcher.resources {
+		dispatcher.startWorker(entry)
+	}
+}
+
+func (dispatcher *messageDispatcher) finish() {
+	dispatcher.lock.Lock()
+	defer dispatcher.lock.Unlock()
+	if nil != dispatcher.stop {
+		close(dispatcher.stop)
+		dispatcher.stop = nil
+	}
+}
+
+//find return handler and stop channel of workers
+func (dispatcher *messageDispatcher) find(id MessageID) (entry *dispatchEntry, stop chan bool, exists bool) {
+	dispatcher.lock.RLock()
+	defer dispatcher.lock.RUnlock()
+	stop = dispatcher.stop
+	if entry, exists = dispatcher.messages[id]; exists {
+		return
+	}
+	entry, exists = dispatcher.resources[GetMessageResource(id)]
+	return
+}
+
+//RegisterMessageHandler process message with id by handle instead of OnMessageReceived
+func (endpoint *EndpointService) RegisterMessageHandler(id MessageID, handle MessageHandleFunc, options ...DispatchOption) error {
+	entry, err := createDispatchEntry(handle, options)
+	if err != nil {
+		return err
+	}
+	return endpoint.dispatcher.addMessage(id, entry)
+}
+
+//RegisterResourceHandler process all messages of resource without handler registered for its id
+func (endpoint *EndpointService) RegisterResourceHandler(resource uint, handle MessageHandleFunc, options ...DispatchOption) error {
+	entry, err := createDispatchEntry(handle, options)
+	if err != nil {
+		return err
+	}
+	return endpoint.dispatcher.addResource(resource, entry)
+}
+
+//dispatch message to registered handler, then transaction engine, OnMessageReceived when neither available
+func (endpoint *EndpointService) dispatch(msg Message) {
+	if entry, stop, exists := endpoint.dispatcher.find(msg.GetID()); exists {
+		entry.deliver(msg, stop)
+		return
+	}
+	if endpoint.routeTransaction(msg) {
+		return
+	}
+	endpoint.handler.OnMessageReceived(msg)
+}
@@ -0,0 +1,64 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_MessageFields(t *testing.T) {
+	if MessageEvent != GetMessageType(GuestStartedEvent) || MessageRequest != GetMessageType(QueryGuestRequest) {
+		t.Fatal("unexpected message type")
+	}
+	if ResourceGuest != GetMessageResource(GuestStartedEvent) || ResourceComputePool != GetMessageResource(ComputePoolReadyEvent) {
+		t.Fatal("unexpected message resource")
+	}
+	if EventReady != GetMessageOperate(ComputePoolReadyEvent) {
+		t.Fatal("unexpected message operate")
+	}
+}
+
+func Test_MessageDispatch(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core, cell = startMemoryPair(t, network)
+	var poolChan = make(chan Message, 1)
+	var guestChan = make(chan Message, 4)
+	if err := cell.RegisterMessageHandler(ComputePoolReadyEvent, func(msg Message) {
+		poolChan <- msg
+	}); err != nil {
+		t.Fatalf("register message handler fail: %s", err.Error())
+	}
+	if err := cell.RegisterMessageHandler(ComputePoolReadyEvent, func(msg Message) {}); err == nil {
+		t.Fatal("duplicate message handler registered")
+	}
+	if err := cell.RegisterResourceHandler(ResourceGuest, func(msg Message) {
+		guestChan <- msg
+	}, WithHandlerWorker(4)); err != nil {
+		t.Fatalf("register resource handler fail: %s", err.Error())
+	}
+	if err := cell.RegisterResourceHandler(ResourceGuest, func(msg Message) {}); err == nil {
+		t.Fatal("duplicate resource handler registered")
+	}
+	if err := cell.RegisterResourceHandler(ResourceInstance, func(msg Message) {}, WithHandlerWorker(0)); err == nil {
+		t.Fatal("invalid worker queue accepted")
+	}
+	var send = func(id MessageID) {
+		msg, _ := CreateJsonMessage(id)
+		if err := core.SendMessage(msg, cell.GetName()); err != nil {
+			t.Fatalf("send message fail: %s", err.Error())
+		}
+	}
+	send(ComputePoolReadyEvent)
+	receiveEvent(t, poolChan, ComputePoolReadyEvent)
+	send(GuestStartedEvent)
+	receiveEvent(t, guestChan, GuestStartedEvent)
+	send(GuestStoppedEvent)
+	receiveEvent(t, guestChan, GuestStoppedEvent)
+	//fallback to handler
+	send(AddressPoolChangedEvent)
+	receiveEvent(t, cell.MessageChan, AddressPoolChangedEvent)
+	select {
+	case msg := <-cell.MessageChan:
+		t.Fatalf("unexpected message %08X received by handler", uint32(msg.GetID()))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,303 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//LeaderHandler optional for ServiceHandler of stub endpoint with election enabled
+type LeaderHandler interface {
+	OnBecameLeader()
+	OnLostLeadership()
+}
+
+const (
+	DefaultElectionLease = 9 * time.Second
+	//lease renewed several times before expired
+	electionRenewRatio = 3
+)
+
+//electionState lease based election among stubs in the same domain,
+//stubs connect each other and claim lease periodically,
+//the stub with the lowest priority and name leads when no valid leader exists,
+//a valid leader never preempted by stub joined later
+type electionState struct {
+	lock       sync.Mutex
+	lease      time.Duration
+	leader     int32
+	started    time.Time
+	stop       chan bool
+	exited     chan bool
+	candidates map[string]electionCandidate
+	dialed     map[string]string //address of stub => name
+}
+
+type electionCandidate struct {
+	Priority int
+	Leader   bool
+	Renewed  time.Time
+}
+
+//WithLeaderElection elect one active stub among stubs in the same domain, the others keep standby,
+//a standby stub only accepts connections from other stubs, so peers always served by the leader
+func WithLeaderElection(lease time.Duration) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		if endpoint.isPeer {
+			return errors.New("election only available for stub endpoint")
+		}
+		if lease <= 0 {
+			return fmt.Errorf("invalid lease %s", lease)
+		}
+		endpoint.election = &electionState{lease: lease}
+		return nil
+	})
+}
+
+//IsLeader return true when stub is the active one in domain, always false when election disabled
+func (endpoint *EndpointService) IsLeader() bool {
+	return nil != endpoint.election && 1 == atomic.LoadInt32(&endpoint.election.leader)
+}
+
+func (endpoint *EndpointService) isStandby() bool {
+	return nil != endpoint.election && !endpoint.IsLeader()
+}
+
+//rankBefore compare priority first, then name
+func rankBefore(priority int, name string, otherPriority int, otherName string) bool {
+	if priority != otherPriority {
+		return priority < otherPriority
+	}
+	return name < otherName
+}
+
+func (election *electionState) setLeader(leading bool) {
+	if leading {
+		atomic.StoreInt32(&election.leader, 1)
+	} else {
+		atomic.StoreInt32(&election.leader, 0)
+	}
+}
+
+func (election *electionState) start() (stop, exited chan bool) {
+	election.lock.Lock()
+	defer election.lock.Unlock()
+	election.started = time.Now()
+	election.candidates = map[string]electionCandidate{}
+	election.dialed = map[string]string{}
+	election.stop = make(chan bool)
+	election.exited = make(chan bool)
+	election.setLeader(false)
+	return election.stop, election.exited
+}
+
+//finish wait until routine exited, which may be locating or dialing other stubs
+func (election *electionState) finish() {
+	election.lock.Lock()
+	var stop, exited = election.stop, election.exited
+	election.stop, election.exited = nil, nil
+	election.lock.Unlock()
+	if nil != stop {
+		close(stop)
+		<-exited
+	}
+	election.setLeader(false)
+}
+
+func (election *electionState) renew(name string, candidate electionCandidate) {
+	election.lock.Lock()
+	defer election.lock.Unlock()
+	if nil != election.candidates {
+		election.candidates[name] = candidate
+	}
+}
+
+func (election *electionState) getDialed(address string) (name string, exists bool) {
+	election.lock.Lock()
+	defer election.lock.Unlock()
+	name, exists = election.dialed[address]
+	return
+}
+
+func (election *electionState) setDialed(address, name string) {
+	election.lock.Lock()
+	defer election.lock.Unlock()
+	election.dialed[address] = name
+}
+
+//decide whether local stub should lead, expired candidates removed
+func (election *electionState) decide(name string, priority int, leading bool, now time.Time) bool {
+	election.lock.Lock()
+	defer election.lock.Unlock()
+	var preferred = true
+	for candidateName, candidate := range election.candidates {
+		if candidate.Renewed.Add(election.lease).Before(now) {
+			delete(election.candidates, candidateName)
+			continue
+		}
+		var before = rankBefore(candidate.Priority, candidateName, priority, name)
+		if candidate.Leader && (!leading || before) {
+			//respect valid leader, or step down when conflict with preferred one
+			return false
+		}
+		if before {
+			preferred = false
+		}
+	}
+	if leading {
+		return true
+	}
+	//wait a whole lease for claims of other stubs
+	return preferred && !now.Before(election.started.Add(election.lease))
+}
+
+func (endpoint *EndpointService) startElection() {
+	if nil == endpoint.election {
+		return
+	}
+	go endpoint.electionRoutine(endpoint.election.start())
+}
+
+func (endpoint *EndpointService) stopElection() {
+	if nil == endpoint.election {
+		return
+	}
+	endpoint.election.finish()
+}
+
+func (endpoint *EndpointService) electionRoutine(stop, exited chan bool) {
+	var ticker = time.NewTicker(endpoint.election.lease / electionRenewRatio)
+	defer close(exited)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			endpoint.connectOtherStubs()
+			endpoint.claimLease()
+			endpoint.evaluateLeadership()
+		}
+	}
+}
+
+//connectOtherStubs located in domain, only stub with lower address dial, avoid duplicated connection
+func (endpoint *EndpointService) connectOtherStubs() {
+	_, services, err := endpoint.locator.Locate(endpoint.options.QueryDuration)
+	if err != nil {
+		log.Printf("<endpoint> locate other stubs fail: %s", err.Error())
+		return
+	}
+	var self = fmt.Sprintf("%s:%d", endpoint.listenAddress, endpoint.listenPort)
+	for _, stub := range endpoint.filterStubs(services) {
+		var address = fmt.Sprintf("%s:%d", stub.Address, stub.Port)
+		if address <= self {
+			continue
+		}
+		if name, dialed := endpoint.election.getDialed(address); dialed {
+			if _, exists := endpoint.connections.get(name); exists {
+				continue
+			}
+		}
+		if !endpoint.isRunning() {
+			//stopping, never dial after guardian exited
+			return
+		}
+		name, err := endpoint.dialRemoteService(stub.Address, stub.Port)
+		if err != nil {
+			log.Printf("<endpoint> connect stub %s fail: %s", address, err.Error())
+			continue
+		}
+		endpoint.election.setDialed(address, name)
+	}
+}
+
+//claimLease send priority and leadership to all connected stubs
+func (endpoint *EndpointService) claimLease() {
+	msg, err := CreateJsonMessage(ServiceElectEvent)
+	if err != nil {
+		log.Printf("<endpoint> create message fail:%s", err.Error())
+		return
+	}
+	msg.SetUInt(ParamKeyPriority, uint(endpoint.stubPriority))
+	msg.SetBoolean(ParamKeyFlag, endpoint.IsLeader())
+	for _, entry := range endpoint.connections.list() {
+		if ServiceTypeCore != entry.Type {
+			continue
+		}
+		if err = endpoint.TrySend(msg, entry.Name); err != nil {
+			log.Printf("<endpoint> warning: claim lease to '%s' fail: %s", entry.Name, err.Error())
+		}
+	}
+}
+
+func (endpoint *EndpointService) evaluateLeadership() {
+	var election = endpoint.election
+	var leading = endpoint.IsLeader()
+	var elected = election.decide(endpoint.name, endpoint.stubPriority, leading, time.Now())
+	if elected == leading || !endpoint.isRunning() {
+		return
+	}
+	election.setLeader(elected)
+	if elected {
+		log.Printf("<endpoint> %s became leader of domain '%s'", endpoint.name, endpoint.domain)
+	} else {
+		log.Printf("<endpoint> %s lost leadership of domain '%s'", endpoint.name, endpoint.domain)
+		endpoint.releasePeers()
+	}
+	msg, err := CreateJsonMessage(ServiceLeaderChangedEvent)
+	if err != nil {
+		log.Printf("<endpoint> create message fail:%s", err.Error())
+		return
+	}
+	msg.SetBoolean(ParamKeyFlag, elected)
+	if err = endpoint.SendToSelf(msg); err != nil {
+		log.Printf("<endpoint> notify leader changed event fail: %s", err.Error())
+	}
+}
+
+//releasePeers disconnect all peers served by standby stub, so that they fail over to the leader
+func (endpoint *EndpointService) releasePeers() {
+	for _, entry := range endpoint.connections.list() {
+		if ServiceTypeCore == entry.Type {
+			continue
+		}
+		if err := endpoint.disconnectRemoteService(entry.Name, entry); err != nil {
+			log.Printf("<endpoint> release peer '%s' fail: %s", entry.Name, err.Error())
+		}
+	}
+}
+
+func (endpoint *EndpointService) handleElectEvent(incoming incomingMessage) {
+	if nil == endpoint.election {
+		return
+	}
+	var msg = incoming.Message
+	if !incoming.Remote || ServiceTypeCore != incoming.Type {
+		//only stubs compete for leadership
+		log.Printf("<endpoint> warning: ignore claim of '%s' (type %d)", msg.GetSender(), incoming.Type)
+		return
+	}
+	priority, err := msg.GetUInt(ParamKeyPriority)
+	if err != nil {
+		log.Printf("<endpoint> get priority fail:%s", err.Error())
+		return
+	}
+	leading, _ := msg.GetBoolean(ParamKeyFlag)
+	endpoint.election.renew(incoming.Name, electionCandidate{int(priority), leading, time.Now()})
+}
+
+func (endpoint *EndpointService) handleLeaderChangedEvent(msg Message) {
+	handler, implemented := endpoint.handler.(LeaderHandler)
+	if !implemented {
+		return
+	}
+	if leading, _ := msg.GetBoolean(ParamKeyFlag); leading {
+		handler.OnBecameLeader()
+	} else {
+		handler.OnLostLeadership()
+	}
+}
@@ -0,0 +1,224 @@
+package framework
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type leaderPeer struct {
+	*memoryPeer
+	LeaderChan chan bool
+}
+
+func (peer *leaderPeer) OnBecameLeader() {
+	peer.LeaderChan <- true
+}
+
+func (peer *leaderPeer) OnLostLeadership() {
+	peer.LeaderChan <- false
+}
+
+func createLeaderPeer(t *testing.T, network *MemoryNetwork, index byte, lease time.Duration) *leaderPeer {
+	var peer = &leaderPeer{createMemoryPeer(t, network, ServiceTypeCore, index, WithLeaderElection(lease)),
+		make(chan bool, 8)}
+	peer.handler = peer
+	return peer
+}
+
+func waitLeadership(t *testing.T, peer *leaderPeer, expected bool) {
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s wait leadership timeout", peer.GetName())
+	case leading := <-peer.LeaderChan:
+		if leading != expected {
+			t.Fatalf("%s: unexpected leadership %t", peer.GetName(), leading)
+		}
+	}
+}
+
+func waitPeerConnected(t *testing.T, peer *memoryPeer, target string) {
+	var deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := peer.GetPeerInfo(target); err == nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("%s wait '%s' connected timeout", peer.GetName(), target)
+}
+
+func Test_LeaderElection(t *testing.T) {
+	const (
+		lease = 300 * time.Millisecond
+	)
+	var network = CreateMemoryNetwork()
+	var primary = createLeaderPeer(t, network, 0, lease)
+	var standby = createLeaderPeer(t, network, 1, lease)
+	//standby published first, tried first by peer
+	for _, stub := range []*leaderPeer{standby, primary} {
+		if err := stub.Start(); err != nil {
+			t.Fatalf("start %s fail: %s", stub.GetName(), err.Error())
+		}
+	}
+	defer standby.Stop()
+	waitLeadership(t, primary, true)
+	time.Sleep(2 * lease)
+	if !primary.IsLeader() || standby.IsLeader() {
+		t.Fatalf("unexpected leadership %t/%t", primary.IsLeader(), standby.IsLeader())
+	}
+	var peer = createMemoryPeer(t, network, ServiceTypeCell, 2,
+		ReconnectPolicy{InitialDelay: 100 * time.Millisecond, Multiplier: 1, MaxDelay: 100 * time.Millisecond})
+	if err := peer.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	defer peer.Stop()
+	waitPeerConnected(t, peer, primary.GetName())
+	if _, err := peer.GetPeerInfo(standby.GetName()); err == nil {
+		t.Fatal("peer accepted by standby")
+	}
+	//rejected by standby never reported as disconnection
+	waitMemoryEvent(t, peer.EventChan, "primary connected")
+	select {
+	case <-peer.EventChan:
+		t.Fatal("unexpected event when rejected by standby")
+	default:
+	}
+	if err := primary.Stop(); err != nil {
+		t.Fatalf("stop primary fail: %s", err.Error())
+	}
+	waitLeadership(t, standby, true)
+	waitPeerConnected(t, peer, standby.GetName())
+}
+
+func Test_ClaimFromNonCore(t *testing.T) {
+	const (
+		lease = 300 * time.Millisecond
+	)
+	var network = CreateMemoryNetwork()
+	var leader = createLeaderPeer(t, network, 0, lease)
+	if err := leader.Start(); err != nil {
+		t.Fatalf("start %s fail: %s", leader.GetName(), err.Error())
+	}
+	defer leader.Stop()
+	waitLeadership(t, leader, true)
+	var cell = createMemoryPeer(t, network, ServiceTypeCell, 1)
+	if err := cell.Start(); err != nil {
+		t.Fatalf("start cell fail: %s", err.Error())
+	}
+	defer cell.Stop()
+	waitPeerConnected(t, cell, leader.GetName())
+	//cell ranked before leader, never preferred
+	claim, _ := CreateJsonMessage(ServiceElectEvent)
+	claim.SetUInt(ParamKeyPriority, 0)
+	claim.SetBoolean(ParamKeyFlag, true)
+	if err := cell.SendMessage(claim, leader.GetName()); err != nil {
+		t.Fatalf("send claim fail: %s", err.Error())
+	}
+	time.Sleep(lease)
+	select {
+	case leading := <-leader.LeaderChan:
+		t.Fatalf("leadership changed to %t by claim of cell", leading)
+	default:
+	}
+	if !leader.IsLeader() {
+		t.Fatal("leader step down for claim of cell")
+	}
+}
+
+//slowLocator delay locating, so that election routine still running when endpoint stopped
+type slowLocator struct {
+	StubLocator
+	Delay   time.Duration
+	Entered chan bool
+}
+
+func (locator *slowLocator) Locate(timeout time.Duration) (string, []ServiceLocation, error) {
+	select {
+	case locator.Entered <- true:
+	default:
+	}
+	time.Sleep(locator.Delay)
+	return locator.StubLocator.Locate(timeout)
+}
+
+func Test_StopDuringElection(t *testing.T) {
+	const (
+		lease = 300 * time.Millisecond
+		delay = 500 * time.Millisecond
+	)
+	var network = CreateMemoryNetwork()
+	var stubs = []*leaderPeer{createLeaderPeer(t, network, 0, lease), createLeaderPeer(t, network, 1, lease)}
+	var locators []*slowLocator
+	for _, stub := range stubs {
+		var locator = &slowLocator{stub.locator, delay, make(chan bool, 1)}
+		stub.locator = locator
+		locators = append(locators, locator)
+		if err := stub.Start(); err != nil {
+			t.Fatalf("start %s fail: %s", stub.GetName(), err.Error())
+		}
+	}
+	//only stub with lower address dial
+	var address = func(stub *leaderPeer) string {
+		return fmt.Sprintf("%s:%d", stub.GetListenAddress(), stub.GetListenPort())
+	}
+	var dialer, other, locator = stubs[0], stubs[1], locators[0]
+	if address(dialer) > address(other) {
+		dialer, other, locator = stubs[1], stubs[0], locators[1]
+	}
+	defer other.Stop()
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait locating timeout")
+	case <-locator.Entered:
+	}
+	if err := dialer.Stop(); err != nil {
+		t.Fatalf("stop %s fail: %s", dialer.GetName(), err.Error())
+	}
+	time.Sleep(2 * delay)
+	select {
+	case <-other.EventChan:
+		t.Fatal("stub dialed after stopped")
+	default:
+	}
+	if dialer.IsLeader() {
+		t.Fatal("stopped stub became leader")
+	}
+}
+
+func Test_LeaderDecide(t *testing.T) {
+	const (
+		lease = time.Second
+	)
+	var election = &electionState{lease: lease}
+	election.start()
+	var now = election.started
+	if election.decide("Core_b", 0, false, now) {
+		t.Fatal("elected before lease passed")
+	}
+	now = now.Add(lease)
+	if !election.decide("Core_b", 0, false, now) {
+		t.Fatal("not elected without other stubs")
+	}
+	//preferred stub in standby
+	election.renew("Core_a", electionCandidate{0, false, now})
+	if election.decide("Core_b", 0, false, now) {
+		t.Fatal("elected with preferred stub")
+	}
+	//lower priority never preempt valid leader
+	election.renew("Core_c", electionCandidate{1, true, now})
+	if election.decide("Core_a", 0, false, now) {
+		t.Fatal("valid leader preempted")
+	}
+	if !election.decide("Core_b", 0, true, now) {
+		t.Fatal("leader step down for less preferred stub")
+	}
+	if election.decide("Core_d", 2, true, now) {
+		t.Fatal("conflicted leader not step down")
+	}
+	//expired
+	now = now.Add(2 * lease)
+	if !election.decide("Core_b", 0, false, now) {
+		t.Fatal("not elected after lease of others expired")
+	}
+}
@@ -1,13 +1,14 @@
 package framework
 
 import (
-	"github.com/project-nano/sonar"
 	"net"
 	"fmt"
 	"errors"
 	"github.com/xtaci/kcp-go"
 	"log"
 	"time"
+	"strconv"
+	"sync/atomic"
 )
 
 //need overwriting
@@ -28,18 +29,17 @@ type MessageSender interface {
 
 type EndpointService struct {
 	isPeer              bool
-	isReady             bool
-	groupListener       *sonar.Listener
-	groupPinger         *sonar.Pinger
+	serviceReady        int32
+	publisher           StubPublisher
+	locator             StubLocator
 	fixedListenAddress  string
-	connectionListener  *kcp.Listener
-	connectionMap       map[string]connEntry
+	connectionListener  TransportListener
+	connections         *connectionTable
 	connEventChan       chan connEvent
-	incomingMessageChan chan Message
+	incomingMessageChan chan incomingMessage
 	guardianNotifyChan  chan bool
 	guardianFinishChan  chan bool
 	status              serviceStatus
-	submoduleChannel    map[string]chan Message
 	listenAddress       string
 	listenPort          int
 	name                string
@@ -47,9 +47,31 @@ type EndpointService struct {
 	domain              string
 	groupAddress        string
 	groupPort           int
-	stubAvailable       bool
-	recoveringStub      bool
+	stubAvailable       int32
+	recoveringStub      int32
 	handler             ServiceHandler
+	wireCodecs          []CodecID
+	compressions        []CompressID
+	buildVersion        string
+	capabilities        []string
+	minPeerVersion      uint
+	requiredCapability  []string
+	domainKey           []byte
+	newCrypt            func() (kcp.BlockCrypt, error)
+	transport           Transport
+	requests            *requestTable
+	overflows           *overflowTable
+	options             EndpointOptions
+	reconnectPolicy     *ReconnectPolicy
+	stubPriority        int
+	stubs               *stubList
+	election            *electionState
+	dependencies        *dependencyState
+	subscriptions       *subscriptionTable
+	dispatcher          *messageDispatcher
+	interceptors        *interceptorChain
+	engine              *TransactionEngine
+	access              *accessControl
 }
 
 const (
@@ -61,7 +83,7 @@ const (
 	DefaultMessageQueueSize = 1 << 10
 )
 
-type serviceStatus int
+type serviceStatus int32
 
 const (
 	serviceStatusStopped  = iota
@@ -69,37 +91,123 @@ const (
 	serviceStatusRunning
 )
 
-func CreateStubEndpoint(groupAddress string, groupPort int, domain, listenAddress string) (endpoint EndpointService, err error) {
-	listenInterface, err := getInterfaceByAddress(listenAddress)
-	if err != nil{
+func CreateStubEndpoint(groupAddress string, groupPort int, domain, listenAddress string, options ...EndpointOption) (endpoint EndpointService, err error) {
+	endpoint = EndpointService{isPeer: false, fixedListenAddress: listenAddress,
+		domain: domain, groupAddress: groupAddress, groupPort: groupPort,
+		status: serviceStatusStopped,
+		wireCodecs: defaultWireCodecs(), compressions: defaultCompressions(), buildVersion: FrameworkVersion,
+		capabilities: defaultCapabilities(), minPeerVersion: LegacyProtocolVersion, 
+		requests: newRequestTable(), connections: newConnectionTable(), options: DefaultEndpointOptions(),
+		stubs: newStubList(), dependencies: newDependencyState(), subscriptions: newSubscriptionTable(),
+		dispatcher: newMessageDispatcher(), interceptors: newInterceptorChain(),
+		access: &accessControl{}, overflows: newOverflowTable()}
+	if err = applyEndpointOptions(&endpoint, options); err != nil {
 		return
 	}
-	listener, err := sonar.CreateListener(groupAddress, groupPort, domain, listenInterface)
-	if err != nil {
-		return endpoint, err
+	if nil == endpoint.publisher{
+		if endpoint.publisher, err = createSonarPublisher(groupAddress, groupPort, domain, listenAddress); err != nil{
+			return
+		}
+	}
+	if nil != endpoint.election && nil == endpoint.locator{
+		//find other stubs for election
+		if endpoint.locator, err = createSonarLocator(groupAddress, groupPort, domain); err != nil{
+			return
+		}
+	}
+	if err = endpoint.prepareTransport(); err != nil{
+		return
 	}
-	return EndpointService{isPeer: false, groupListener: listener, fixedListenAddress: listenAddress,
+	return endpoint, nil
+}
+
+func CreatePeerEndpoint(groupAddress string, groupPort int, domain string, options ...EndpointOption) (endpoint EndpointService, err error) {
+	endpoint = EndpointService{isPeer: true, status: serviceStatusStopped,
 		domain: domain, groupAddress: groupAddress, groupPort: groupPort,
-		status: serviceStatusStopped, submoduleChannel:map[string]chan Message{}, stubAvailable: false, recoveringStub: false}, nil
+		wireCodecs: defaultWireCodecs(), compressions: defaultCompressions(), buildVersion: FrameworkVersion,
+		capabilities: defaultCapabilities(), minPeerVersion: LegacyProtocolVersion, 
+		requests: newRequestTable(), connections: newConnectionTable(), options: DefaultEndpointOptions(),
+		stubs: newStubList(), dependencies: newDependencyState(), subscriptions: newSubscriptionTable(),
+		dispatcher: newMessageDispatcher(), interceptors: newInterceptorChain(),
+		access: &accessControl{}, overflows: newOverflowTable()}
+	if err = applyEndpointOptions(&endpoint, options); err != nil {
+		return
+	}
+	if nil == endpoint.locator{
+		if endpoint.locator, err = createSonarLocator(groupAddress, groupPort, domain); err != nil{
+			return
+		}
+	}
+	if err = endpoint.prepareTransport(); err != nil{
+		return
+	}
+	return endpoint, nil
 }
 
-func CreatePeerEndpoint(groupAddress string, groupPort int, domain string) (endpoint EndpointService, err error) {
-	pinger, err := sonar.CreatePinger(groupAddress, groupPort, domain)
-	if err != nil {
-		return endpoint, err
+//preferred first
+func defaultWireCodecs() []CodecID {
+	return []CodecID{CodecBinary, CodecJson}
+}
+
+func defaultCompressions() []CompressID {
+	return []CompressID{CompressFlate, CompressNone}
+}
+
+func defaultCapabilities() []string {
+	return []string{CapabilityFraming, CapabilityCompress, CapabilityEcho}
+}
+
+//SetBuildVersion set build version advertised to remote endpoint, framework version by default
+func (endpoint *EndpointService) SetBuildVersion(version string) {
+	endpoint.buildVersion = version
+}
+
+//AddCapability advertise an application defined capability to remote endpoint
+func (endpoint *EndpointService) AddCapability(capability string) {
+	if !containsString(endpoint.capabilities, capability) {
+		endpoint.capabilities = append(endpoint.capabilities, capability)
 	}
-	return EndpointService{isPeer: true, groupPinger: pinger, status: serviceStatusStopped, submoduleChannel:map[string]chan Message{},
-		domain: domain, groupAddress: groupAddress, groupPort: groupPort, stubAvailable: false, recoveringStub: false}, nil
 }
 
-func (endpoint *EndpointService)RegisterSubmodule(name string, channel chan Message) error{
-	if _, exists := endpoint.submoduleChannel[name];exists{
-		return fmt.Errorf("submodule '%s' already exists", name)
+//SetPeerRequirement reject remote endpoint with lower protocol version or lacking any required capability
+func (endpoint *EndpointService) SetPeerRequirement(minVersion uint, capabilities []string) error {
+	if minVersion > ProtocolVersion {
+		return fmt.Errorf("required version %d is higher than current protocol %d", minVersion, ProtocolVersion)
+	}
+	endpoint.minPeerVersion = minVersion
+	endpoint.requiredCapability = capabilities
+	return nil
+}
+
+//SetWireCodecs set codecs advertised in handshake, preferred first
+func (endpoint *EndpointService) SetWireCodecs(codecs []CodecID) error {
+	if 0 == len(codecs) {
+		return errors.New("no codec specified")
+	}
+	for _, id := range codecs {
+		if _, err := GetCodec(id); err != nil {
+			return err
+		}
 	}
-	endpoint.submoduleChannel[name] = channel
+	endpoint.wireCodecs = codecs
 	return nil
 }
 
+//SetCompressions set compressions advertised in handshake, preferred first
+func (endpoint *EndpointService) SetCompressions(compressions []CompressID) error {
+	for _, compress := range compressions {
+		if compress > CompressFlate {
+			return fmt.Errorf("unsupported compression %d", compress)
+		}
+	}
+	endpoint.compressions = compressions
+	return nil
+}
+
+func (endpoint *EndpointService)RegisterSubmodule(name string, channel chan Message) error{
+	return endpoint.connections.addSubmodule(name, channel)
+}
+
 func (endpoint *EndpointService) RegisterHandler(h ServiceHandler){
 	endpoint.handler = h
 }
@@ -215,12 +323,36 @@ func (endpoint *EndpointService) GenerateName(t ServiceType, i *net.Interface) e
 
 }
 
+func (endpoint *EndpointService) getStatus() serviceStatus {
+	return serviceStatus(atomic.LoadInt32((*int32)(&endpoint.status)))
+}
+
+func (endpoint *EndpointService) setStatus(status serviceStatus) {
+	atomic.StoreInt32((*int32)(&endpoint.status), int32(status))
+}
+
 func (endpoint *EndpointService) isRunning() bool {
-	return endpoint.status == serviceStatusRunning
+	return endpoint.getStatus() == serviceStatusRunning
 }
 
 func (endpoint *EndpointService) isStopped() bool {
-	return endpoint.status == serviceStatusStopped
+	return endpoint.getStatus() == serviceStatusStopped
+}
+
+func (endpoint *EndpointService) isStopping() bool {
+	return endpoint.getStatus() == serviceStatusStopping
+}
+
+func (endpoint *EndpointService) isStubAvailable() bool {
+	return 1 == atomic.LoadInt32(&endpoint.stubAvailable)
+}
+
+func (endpoint *EndpointService) setStubAvailable(available bool) {
+	if available {
+		atomic.StoreInt32(&endpoint.stubAvailable, 1)
+	} else {
+		atomic.StoreInt32(&endpoint.stubAvailable, 0)
+	}
 }
 
 func (endpoint *EndpointService) Start() error {
@@ -230,6 +362,13 @@ func (endpoint *EndpointService) Start() error {
 	if err := endpoint.handler.InitialEndpoint(); err != nil {
 		return err
 	}
+	atomic.StoreInt32(&endpoint.serviceReady, 0)
+	endpoint.dependencies.reset()
+	endpoint.dispatcher.start()
+	if err := endpoint.startEngine(); err != nil {
+		endpoint.dispatcher.finish()
+		return err
+	}
 	var err error
 	if endpoint.isPeer {
 		err = endpoint.startPeerService()
@@ -237,9 +376,12 @@ func (endpoint *EndpointService) Start() error {
 		err = endpoint.startCoreService()
 	}
 	if err != nil {
+		endpoint.stopEngine()
+		endpoint.dispatcher.finish()
 		return err
 	}
-	endpoint.status = serviceStatusRunning
+	endpoint.setStatus(serviceStatusRunning)
+	endpoint.startElection()
 	return nil
 }
 
@@ -247,27 +389,37 @@ func (endpoint *EndpointService) Stop() error {
 	if !endpoint.isRunning() {
 		return errors.New("endpoint not running")
 	}
-	endpoint.status = serviceStatusStopping
+	endpoint.setStatus(serviceStatusStopping)
+	endpoint.stopElection()
 	endpoint.handler.OnEndpointStopped()
 	if err := endpoint.connectionListener.Close(); err != nil {
-		endpoint.status = serviceStatusStopped
+		endpoint.setStatus(serviceStatusStopped)
 		return err
 	}
 	endpoint.guardianNotifyChan <- true
 	<-endpoint.guardianFinishChan
-	close(endpoint.connEventChan)
-	close(endpoint.incomingMessageChan)
-	endpoint.status = serviceStatusStopped
+	//channels not closed, connection still in handshake may post later
+	endpoint.incomingMessageChan <- incomingMessage{}
+	endpoint.requests.cancelAll()
+	endpoint.dispatcher.finish()
+	endpoint.stopEngine()
+	endpoint.setStatus(serviceStatusStopped)
 	return nil
 }
 
-//all dependent service must ready before prepare local service
-func (endpoint *EndpointService) AddDependency(dependencies []ServiceType) {
-	panic("not implement")
+//GetPeerInfo return version, capabilities and negotiated codec of a connected service
+func (endpoint *EndpointService) GetPeerInfo(name string) (info PeerInfo, err error){
+	entry, exists := endpoint.connections.get(name)
+	if !exists{
+		err = fmt.Errorf("invalid service '%s'", name)
+		return
+	}
+	return entry.Peer, nil
 }
 
-func (endpoint *EndpointService) SetServiceReady() {
-	panic("not implement")
+//GetDisconnectReason return the error caused last disconnection of service, available in OnServiceDisconnected
+func (endpoint *EndpointService) GetDisconnectReason(name string) error{
+	return endpoint.connections.getReason(name)
 }
 
 func (endpoint *EndpointService) GetListenAddress() string{
@@ -299,15 +451,16 @@ func (endpoint *EndpointService) GetGroupPort() int{
 }
 //private functions
 func (endpoint *EndpointService) startCoreService() error {
-	listener, listenPort, err := selectAvailablePort(endpoint.fixedListenAddress)
+	listener, listenPort, err := endpoint.selectAvailablePort(endpoint.fixedListenAddress)
 	if err != nil {
 		return err
 	}
-	if err = endpoint.groupListener.AddService(ServiceTypeStringCore, "kcp", endpoint.fixedListenAddress, listenPort); err != nil {
+	if err = endpoint.publisher.Publish(ServiceLocation{ServiceTypeStringCore, endpoint.transport.Protocol(),
+		endpoint.fixedListenAddress, listenPort, endpoint.stubPriority}); err != nil {
 		return err
 	}
 	log.Printf("<endpoint> service %s published for %s:%d", endpoint.name, endpoint.fixedListenAddress, listenPort)
-	if err = endpoint.groupListener.Start(); err != nil {
+	if err = endpoint.publisher.Start(); err != nil {
 		return err
 	}
 	endpoint.listenAddress = endpoint.fixedListenAddress
@@ -316,35 +469,60 @@ func (endpoint *EndpointService) startCoreService() error {
 }
 
 func (endpoint *EndpointService) startPeerService() error {
-	const (
-		DefaultQueryDuration = 5*time.Second
-	)
-	echo, err := endpoint.groupPinger.Query(DefaultQueryDuration)
+	localAddress, services, err := endpoint.locator.Locate(endpoint.options.QueryDuration)
+	if err != nil {
+		return err
+	}
+	var candidates = endpoint.stubs.update(endpoint.filterStubs(services))
+	if 0 == len(candidates){
+		return errors.New("no service available")
+	}
+	//create listener
+	listener, listenPort, err := endpoint.selectAvailablePort(localAddress)
 	if err != nil {
 		return err
 	}
-	//select first service
-	for _, service := range echo.Services {
+	//start routine
+	log.Printf("<endpoint> %s listen at %s:%d", endpoint.name, localAddress, listenPort)
+	endpoint.listenPort = listenPort
+	endpoint.listenAddress = localAddress
+	if err = endpoint.startRoutine(listener); err != nil {
+		return err
+	}
+	//connect preferred service first
+	if err = endpoint.connectStubs(candidates); err != nil{
+		return err
+	}
+	return nil
+}
+
+//filterStubs select core services with supported protocol
+func (endpoint *EndpointService) filterStubs(services []ServiceLocation) (stubs []ServiceLocation){
+	for _, service := range services {
 		if ServiceTypeStringCore != service.Type {
 			log.Printf("<endpoint> warning:invalid service type `%s` in echo response", service.Type)
 			continue
 		}
-		//create listener
-		listener, listenPort, err := selectAvailablePort(echo.LocalAddress)
-		if err != nil {
-			return err
+		if !endpoint.supportProtocol(service.Protocol){
+			log.Printf("<endpoint> warning:ignore service %s:%d with protocol `%s`", service.Address, service.Port, service.Protocol)
+			continue
 		}
-		//start routine
-		log.Printf("<endpoint> %s listen at %s:%d", endpoint.name, echo.LocalAddress, listenPort)
-		endpoint.listenPort = listenPort
-		endpoint.listenAddress = echo.LocalAddress
-		if err = endpoint.startRoutine(listener); err != nil {
-			return err
+		stubs = append(stubs, service)
+	}
+	return stubs
+}
+
+//connectStubs try stubs in order until one connected
+func (endpoint *EndpointService) connectStubs(stubs []ServiceLocation) (err error){
+	for _, stub := range stubs{
+		if err = endpoint.connectRemoteService(stub.Address, stub.Port); err == nil{
+			endpoint.stubs.setCurrent(stub)
+			log.Printf("<endpoint> stub %s:%d connected", stub.Address, stub.Port)
+			return nil
 		}
-		//connect service
-		return endpoint.connectRemoteService(service.Address, service.Port)
+		log.Printf("<endpoint> connect stub %s:%d fail: %s", stub.Address, stub.Port, err.Error())
 	}
-	return errors.New("no service available")
+	return err
 }
 
 func (endpoint *EndpointService) SendMessage(msg Message, target string) error {
@@ -355,34 +533,36 @@ func (endpoint *EndpointService) SendMessage(msg Message, target string) error {
 		return endpoint.SendToSelf(msg)
 	}
 	//inner submodule first
-	channel, exists := endpoint.submoduleChannel[target]
+	channel, exists := endpoint.connections.getSubmodule(target)
 	if exists{
 		channel <- msg
 		return nil
 	}
-	entry, exists := endpoint.connectionMap[target]
+	entry, exists := endpoint.connections.get(target)
 	if !exists {
 		return fmt.Errorf("invalid target '%s'", target)
 	}
-	entry.OutgoingChan <- msg
-	return nil
+	if send, err := endpoint.interceptOutbound(entry, msg); !send {
+		return err
+	}
+	return endpoint.enqueue(entry, msg)
 }
 
 func (endpoint *EndpointService) SendToSelf(msg Message) error {
 	if "" == msg.GetSender(){
 		msg.SetSender(endpoint.name)
 	}
-	endpoint.incomingMessageChan <- msg
+	endpoint.incomingMessageChan <- incomingMessage{Message: msg}
 	return nil
 }
 
-func (endpoint *EndpointService) startRoutine(listener *kcp.Listener) error {
+func (endpoint *EndpointService) startRoutine(listener TransportListener) error {
 	endpoint.connectionListener = listener
-	endpoint.connEventChan = make(chan connEvent, DefaultMessageQueueSize)
-	endpoint.incomingMessageChan = make(chan Message, DefaultMessageQueueSize)
+	endpoint.connEventChan = make(chan connEvent, endpoint.options.MessageQueueSize)
+	endpoint.incomingMessageChan = make(chan incomingMessage, endpoint.options.MessageQueueSize)
 	endpoint.guardianNotifyChan = make(chan bool, 1)
 	endpoint.guardianFinishChan = make(chan bool, 1)
-	endpoint.connectionMap = map[string]connEntry{}
+	endpoint.connections.reset()
 	go endpoint.listenRoutine()
 	go endpoint.guardianRoutine()
 	go endpoint.mainRoutine()
@@ -392,7 +572,7 @@ func (endpoint *EndpointService) startRoutine(listener *kcp.Listener) error {
 func (endpoint *EndpointService) listenRoutine() {
 	//listen&accept
 	for {
-		session, err := endpoint.connectionListener.AcceptKCP()
+		session, err := endpoint.connectionListener.Accept()
 		if err != nil {
 			break
 		}
@@ -407,9 +587,13 @@ type connEvent struct {
 	Address      string
 	Port         int
 	Gracefully   bool
-	Conn         *kcp.UDPSession
+	Conn         *frameConn
 	OutgoingChan chan Message
 	FinishChan   chan bool
+	Peer         PeerInfo
+	Reason       string
+	Sequence     uint64 //echoed keep alive, zero for others
+	RTT          time.Duration
 }
 
 type connEntry struct {
@@ -417,9 +601,16 @@ type connEntry struct {
 	Type          ServiceType
 	Status        connectionStatus
 	LastHeartBeat time.Time
-	Session       *kcp.UDPSession
+	Session       *frameConn
 	OutgoingChan  chan Message
 	FinishChan    chan bool
+	Peer          PeerInfo
+	Counter       *sendCounter
+	Ready         bool //remote service declared ready
+	Address       string
+	Established   time.Time
+	Latency       *latencyMeter
+	Overflow      overflowSetting
 }
 
 type connEventType int
@@ -442,14 +633,9 @@ const (
 
 func (endpoint *EndpointService) guardianRoutine() {
 	var exitFlag = false
-	const (
-		CheckInterval               = time.Second * 5
-		KeepAliveInterval           = time.Second * 3
-		LostThresholdInterval       = time.Second * 9
-		DisconnectThresholdInterval = time.Second * 15
-	)
-	var checkTicker = time.NewTicker(CheckInterval)
-	var keepAliveTicker = time.NewTicker(KeepAliveInterval)
+	var options = endpoint.options
+	var checkTicker = time.NewTicker(options.CheckInterval)
+	var keepAliveTicker = time.NewTicker(options.KeepAliveInterval)
 
 	for !exitFlag {
 		select {
@@ -461,15 +647,16 @@ func (endpoint *EndpointService) guardianRoutine() {
 			switch event.Event {
 			case ConnEventOpen:
 				{
-					if _, exists := endpoint.connectionMap[event.Name]; exists {
+					var now = time.Now()
+					if !endpoint.overflows.attach(endpoint.connections, connEntry{event.Name, event.Service, connStatusConnected,
+						now, event.Conn, event.OutgoingChan, event.FinishChan, event.Peer, &sendCounter{}, false,
+						fmt.Sprintf("%s:%d", event.Address, event.Port), now, newLatencyMeter(), overflowSetting{}}) {
 						log.Printf("<endpoint> connection to service '%s' already opened", event.Name)
 						continue
 					}
-					endpoint.connectionMap[event.Name] = connEntry{event.Name, event.Service, connStatusConnected,
-						time.Now(), event.Conn, event.OutgoingChan, event.FinishChan}
 					log.Printf("<endpoint> new connection '%s' opened", event.Name)
-					if !endpoint.stubAvailable && (ServiceTypeCore == event.Service){
-						endpoint.stubAvailable = true
+					if ServiceTypeCore == event.Service{
+						endpoint.setStubAvailable(true)
 					}
 					msg, err := CreateJsonMessage(ServiceConnectedEvent)
 					if err != nil {
@@ -486,18 +673,16 @@ func (endpoint *EndpointService) guardianRoutine() {
 
 				}
 			case ConnEventClose:
-				entry, exists := endpoint.connectionMap[event.Name]
+				entry, exists := endpoint.connections.remove(event.Name)
 				if !exists {
 					log.Printf("<endpoint> service '%s' not exists", event.Name)
 					continue
 				}
 				var serviceType = entry.Type
-				delete(endpoint.connectionMap, event.Name)
 				log.Printf("<endpoint> connection '%s' closed", event.Name)
 				if endpoint.isRunning()&&(ServiceTypeCore == serviceType) && endpoint.isPeer {
-					//todo: verify multiple stub
-					endpoint.stubAvailable = false
-					go endpoint.recoverStubService()
+					endpoint.setStubAvailable(false)
+					go endpoint.failoverStub()
 				}
 				msg, err := CreateJsonMessage(ServiceDisconnectedEvent)
 				if err != nil {
@@ -507,20 +692,30 @@ func (endpoint *EndpointService) guardianRoutine() {
 				msg.SetString(ParamKeyName, event.Name)
 				msg.SetUInt(ParamKeyType, uint(serviceType))
 				msg.SetBoolean(ParamKeyFlag, event.Gracefully)
+				if "" != event.Reason{
+					msg.SetError(event.Reason)
+				}
 				if err = endpoint.SendToSelf(msg); err != nil {
 					log.Printf("<endpoint> notify disconnected event fail: %s", err.Error())
 					continue
 				}
 
 			case ConnEventHeartBeat:
-				entry, exists := endpoint.connectionMap[event.Name]
-				if !exists {
+				var latency LatencyStatistic
+				var measured = false
+				if !endpoint.connections.update(event.Name, func(entry *connEntry) {
+					entry.LastHeartBeat = time.Now()
+					entry.Status = connStatusConnected
+					if 0 != event.Sequence && entry.Latency.echo(event.Sequence, event.RTT){
+						latency, measured = entry.Latency.get(), ServiceTypeCore == entry.Type
+					}
+				}) {
 					log.Printf("<endpoint> invalid service '%s' for heartbeat", event.Name)
 					continue
 				}
-				entry.LastHeartBeat = time.Now()
-				entry.Status = connStatusConnected
-				endpoint.connectionMap[event.Name] = entry
+				if measured && endpoint.isPeer{
+					endpoint.stubs.recordLatency(latency.Smoothed)
+				}
 
 			default:
 				log.Printf("<endpoint> warning: invalid connection event type %d", event.Event)
@@ -528,16 +723,16 @@ func (endpoint *EndpointService) guardianRoutine() {
 			break
 			//keep alive
 		case <-keepAliveTicker.C:
-			keepAlive, err := CreateJsonMessage(ConnectionKeepAliveEvent)
-			if err != nil {
-				log.Printf("<endpoint> warning: build keep alive message fail: %s", err.Error())
-				break
-			}
-			for name, entry := range endpoint.connectionMap {
+			for _, entry := range endpoint.connections.list() {
 				if entry.Status == connStatusConnected {
-					//only send keep alive to connected serivce
-					if err = endpoint.SendMessage(keepAlive, name); err != nil {
-						log.Printf("<endpoint> warning: send keep alive to '%s' fail: %s", name, err.Error())
+					keepAlive, err := createKeepAlive(entry)
+					if err != nil {
+						log.Printf("<endpoint> warning: build keep alive message fail: %s", err.Error())
+						continue
+					}
+					//only send keep alive to connected serivce, never blocked by slow service
+					if err = endpoint.TrySend(keepAlive, entry.Name); err != nil {
+						log.Printf("<endpoint> warning: send keep alive to '%s' fail: %s", entry.Name, err.Error())
 					}
 				}
 			}
@@ -545,19 +740,22 @@ func (endpoint *EndpointService) guardianRoutine() {
 			//check timeout
 		case <-checkTicker.C:
 			var current = time.Now()
-			for name, entry := range endpoint.connectionMap {
+			for _, entry := range endpoint.connections.list() {
+				var name = entry.Name
 				if connStatusConnected == entry.Status {
-					if entry.LastHeartBeat.Add(LostThresholdInterval).Before(current) {
+					if entry.LastHeartBeat.Add(options.LostThreshold).Before(current) {
 						//timeout
-						entry.Status = connStatusLost
-						endpoint.connectionMap[name] = entry
+						endpoint.connections.update(name, func(entry *connEntry) {
+							entry.Status = connStatusLost
+						})
 						log.Printf("<endpoint> service '%s' marked to lost", name)
 					}
 				} else if connStatusLost == entry.Status {
-					if entry.LastHeartBeat.Add(DisconnectThresholdInterval).Before(current) {
+					if entry.LastHeartBeat.Add(options.DisconnectThreshold).Before(current) {
 						//timeout
-						entry.Status = connStatusDisconnected
-						endpoint.connectionMap[name] = entry
+						endpoint.connections.update(name, func(entry *connEntry) {
+							entry.Status = connStatusDisconnected
+						})
 						log.Printf("<endpoint> service '%s' marked to disconnect", name)
 						if err := endpoint.disconnectRemoteService(name, entry); err != nil {
 							log.Printf("<endpoint> try disconnect lost service '%s' fail: %s", name, err.Error())
@@ -571,9 +769,9 @@ func (endpoint *EndpointService) guardianRoutine() {
 	}
 	checkTicker.Stop()
 	keepAliveTicker.Stop()
-	for name, entry := range endpoint.connectionMap {
-		if err := endpoint.disconnectRemoteService(name, entry);err != nil{
-			log.Printf("<endpoint> disconnect service '%s' fail when stop: %s", name, err.Error())
+	for _, entry := range endpoint.connections.list() {
+		if err := endpoint.disconnectRemoteService(entry.Name, entry);err != nil{
+			log.Printf("<endpoint> disconnect service '%s' fail when stop: %s", entry.Name, err.Error())
 		}
 	}
 	endpoint.guardianFinishChan <- true
@@ -582,21 +780,42 @@ func (endpoint *EndpointService) guardianRoutine() {
 
 func (endpoint *EndpointService) mainRoutine() {
 	//handle incoming message
-	for msg := range endpoint.incomingMessageChan {
-		if !endpoint.isRunning() {
+	for incoming := range endpoint.incomingMessageChan {
+		var msg = incoming.Message
+		if nil == msg {
+			//stopped
 			break
 		}
-		switch msg.GetID() {
-		case ServiceAvailableEvent, ServiceReadyEvent, ServiceConnectedEvent, ServiceDisconnectedEvent:
-			endpoint.handleSystemMessage(msg)
-		default:
-			endpoint.handler.OnMessageReceived(msg)
+		if endpoint.isStopping() {
+			continue
+		}
+		if isSystemMessage(msg.GetID()) {
+			endpoint.handleSystemMessage(incoming)
+			continue
+		}
+		if !endpoint.checkAccess(incoming){
+			//denied by access policy
+			continue
+		}
+		if !endpoint.interceptInbound(incoming){
+			//rejected or consumed by interceptor
+			continue
+		}
+		if endpoint.requests.deliver(msg){
+			//response of SendRequest
+			continue
+		}
+		if endpoint.dispatchSubscribed(incoming){
+			//consumed by submodules
+			continue
 		}
+		endpoint.dispatch(msg)
 	}
 }
 
 
-func (endpoint *EndpointService) handleSystemMessage(msg Message) {
+func (endpoint *EndpointService) handleSystemMessage(incoming incomingMessage) {
+	var msg = incoming.Message
 	switch msg.GetID() {
 	case ServiceConnectedEvent:
 		serviceName, err := msg.GetString(ParamKeyName)
@@ -615,6 +834,11 @@ func (endpoint *EndpointService) handleSystemMessage(msg Message) {
 			return
 		}
 		endpoint.handler.OnServiceConnected(serviceName, ServiceType(serviceType), remoteAddress)
+		if endpoint.IsServiceReady(){
+			endpoint.notifyServiceReady(serviceName, true)
+		}
+		endpoint.resubscribe(serviceName, ServiceType(serviceType))
+		endpoint.checkDependency()
 		return
 	case ServiceDisconnectedEvent:
 		serviceName, err := msg.GetString(ParamKeyName)
@@ -628,65 +852,198 @@ func (endpoint *EndpointService) handleSystemMessage(msg Message) {
 			return
 		}
 		gracefully, _ := msg.GetBoolean(ParamKeyFlag)
+		if "" != msg.GetError(){
+			log.Printf("<endpoint> service '%s' disconnected: %s", serviceName, msg.GetError())
+			endpoint.connections.setReason(serviceName, errors.New(msg.GetError()))
+		}else{
+			endpoint.connections.setReason(serviceName, nil)
+		}
+		endpoint.subscriptions.dropRemote(serviceName)
+		endpoint.requests.cancelTarget(serviceName, fmt.Errorf("service '%s' disconnected", serviceName))
 		endpoint.handler.OnServiceDisconnected(serviceName, ServiceType(serviceType), gracefully)
+		endpoint.checkDependency()
+		return
+	case ServiceReadyEvent:
+		endpoint.handleReadyEvent(msg)
+		return
+	case ServiceReconnectEvent:
+		endpoint.handleReconnectEvent(msg)
+		return
+	case ServiceElectEvent:
+		endpoint.handleElectEvent(incoming)
+		return
+	case ServiceLeaderChangedEvent:
+		endpoint.handleLeaderChangedEvent(msg)
+		return
+	case ServiceSubscribeEvent, ServiceUnsubscribeEvent:
+		endpoint.handleTopicEvent(msg)
 		return
 	}
 }
 
-func (endpoint *EndpointService) handleIncomingConnection(session *kcp.UDPSession) {
+func (endpoint *EndpointService) handleIncomingConnection(session TransportConn) {
 	//receiver
 	//read remote service info
 	//send local service info
-	var remoteAddress = session.RemoteAddr().(*net.UDPAddr)
-	serviceName, serviceType, err := receiveRemoteServiceInfo(session)
+	remoteIP, remotePort, err := splitAddress(session.RemoteAddr())
+	if err != nil{
+		session.Close()
+		log.Printf("<endpoint> invalid remote address %s: %s", session.RemoteAddr().String(), err.Error())
+		return
+	}
+	var conn = newFrameConn(session)
+	conn.SetReadDeadline(time.Now().Add(endpoint.options.HandshakeTimeout))
+	remote, err := receiveRemoteServiceInfo(conn)
 	if err != nil {
 		session.Close()
 		log.Printf("<endpoint> get service info fail:%s", err.Error())
 		return
 	}
-	var outgoingChan = make(chan Message, DefaultMessageQueueSize)
-	var finishChan = make(chan bool, 1)
-	var remoteIP = remoteAddress.IP.String()
-	log.Printf("<endpoint> new service '%s' (type %d) connected from %s:%d", serviceName, serviceType, remoteIP, remoteAddress.Port)
-	endpoint.connEventChan <- connEvent{ConnEventOpen, serviceName, serviceType,
-		remoteIP, remoteAddress.Port, false, session, outgoingChan, finishChan}
+	var serviceName, serviceType = remote.Name, remote.Type
+	var local = endpoint.localHandshake()
+	if remote.supportFraming(){
+		local.negotiate(remote)
+		conn.EnableFraming()
+	}
+	if err = remote.checkCompatible(endpoint.minPeerVersion, endpoint.requiredCapability); err != nil{
+		//name never opened on this connection, may belong to another live connection
+		log.Printf("<endpoint> reject service '%s' from %s:%d: %s", serviceName, remoteIP, remotePort, err.Error())
+		rejectConnection(conn, local, err)
+		return
+	}
+	if endpoint.isStandby() && ServiceTypeCore != serviceType{
+		//peers served by leader only
+		err = errStubStandby
+		log.Printf("<endpoint> reject service '%s' from %s:%d: %s", serviceName, remoteIP, remotePort, err.Error())
+		rejectConnection(conn, local, err)
+		return
+	}
+	if endpoint.authenticationEnabled(){
+		if err = endpoint.prepareAcceptorProof(&local, remote); err != nil{
+			log.Printf("<endpoint> reject unauthenticated service '%s' from %s:%d: %s", serviceName, remoteIP, remotePort, err.Error())
+			rejectConnection(conn, local, err)
+			return
+		}
+	}
 	//notify remote service
-	if err = sendServiceInfo(session, endpoint.name, endpoint.serviceType); err != nil {
-		session.Close()
+	if err = sendServiceInfo(conn, local); err != nil {
+		conn.Close()
 		log.Printf("<endpoint> notify service info fail:%s", err.Error())
 		return
 	}
+	if local.Negotiated{
+		if err = conn.useCodec(local.Codec, local.Compress); err != nil{
+			conn.Close()
+			log.Printf("<endpoint> apply codec fail:%s", err.Error())
+			return
+		}
+	}
+	if endpoint.authenticationEnabled(){
+		if err = endpoint.verifyDialer(conn, local, remote); err != nil{
+			log.Printf("<endpoint> reject unauthenticated service '%s' from %s:%d: %s", serviceName, remoteIP, remotePort, err.Error())
+			rejectConnection(conn, local, err)
+			return
+		}
+		if err = endpoint.sealSession(conn, false, local, remote); err != nil{
+			log.Printf("<endpoint> reject service '%s' from %s:%d: %s", serviceName, remoteIP, remotePort, err.Error())
+			rejectConnection(conn, local, err)
+			return
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+	var outgoingChan = make(chan Message, endpoint.options.OutgoingQueueSize)
+	var finishChan = make(chan bool, 1)
+	log.Printf("<endpoint> new service '%s' (type %d, build %s) connected from %s:%d", serviceName, serviceType,
+		remote.BuildVersion, remoteIP, remotePort)
+	endpoint.connEventChan <- connEvent{ConnEventOpen, serviceName, serviceType,
+		remoteIP, remotePort, false, conn, outgoingChan, finishChan, remote.toPeer(remoteIP, conn), "", 0, 0}
 	//start routine
-	go sessionServeRoutine(serviceName, session, endpoint.incomingMessageChan, outgoingChan, finishChan, endpoint.connEventChan)
+	go sessionServeRoutine(serviceName, serviceType, conn, endpoint.incomingMessageChan, outgoingChan, finishChan, endpoint.connEventChan)
 }
 
 func (endpoint *EndpointService) connectRemoteService(address string, port int) error {
+	_, err := endpoint.dialRemoteService(address, port)
+	return err
+}
+
+//dialRemoteService return name of remote service when connected
+func (endpoint *EndpointService) dialRemoteService(address string, port int) (name string, err error) {
 	//sender:
 	//send local service info
 	//read remote service info
 	var target = fmt.Sprintf("%s:%d", address, port)
-	session, err := kcp.DialWithOptions(target, nil, DefaultDataShards, DefaultParityShards)
+	session, err := endpoint.transport.Dial(target)
 	if err != nil {
-		return err
+		return "", err
 	}
-	if err = sendServiceInfo(session, endpoint.name, endpoint.serviceType); err != nil {
-		session.Close()
-		return err
+	var conn = newFrameConn(session)
+	var local = endpoint.localHandshake()
+	if endpoint.authenticationEnabled(){
+		if local.Nonce, err = generateNonce(); err != nil{
+			conn.Close()
+			return "", err
+		}
 	}
-	remoteName, remoteType, err := receiveRemoteServiceInfo(session)
+	conn.SetReadDeadline(time.Now().Add(endpoint.options.HandshakeTimeout))
+	//open with bare json, so that legacy endpoint can recognize
+	conn.legacy = true
+	if err = sendServiceInfo(conn, local); err != nil {
+		conn.Close()
+		return "", err
+	}
+	remote, err := receiveRemoteServiceInfo(conn)
 	if err != nil {
-		session.Close()
-		return err
+		conn.Close()
+		if rejected, isRejected := err.(*RejectedError); isRejected{
+			if rejected.Standby{
+				//never connected, try the next stub
+				log.Printf("<endpoint> stub at %s in standby", target)
+			}else if endpoint.authenticationEnabled(){
+				//rejection carries no proof, never surface to handler
+				log.Printf("<endpoint> unauthenticated rejection from %s: %s", target, err.Error())
+			}else{
+				endpoint.notifyServiceRejected(rejected.Name, rejected.Type, err)
+			}
+		}
+		return "", err
+	}
+	if endpoint.authenticationEnabled(){
+		if err = endpoint.verifyAcceptor(local, remote); err != nil{
+			log.Printf("<endpoint> reject unauthenticated service '%s' at %s: %s", remote.Name, target, err.Error())
+			rejectConnection(conn, local, err)
+			return "", err
+		}
+	}
+	if err = remote.checkCompatible(endpoint.minPeerVersion, endpoint.requiredCapability); err != nil{
+		rejectConnection(conn, local, err)
+		endpoint.notifyServiceRejected(remote.Name, remote.Type, err)
+		return "", err
+	}
+	if err = conn.applySelection(local, remote); err != nil {
+		conn.Close()
+		return "", err
+	}
+	if endpoint.authenticationEnabled(){
+		if err = endpoint.sendDialerProof(conn, local, remote); err != nil{
+			conn.Close()
+			return "", err
+		}
+		if err = endpoint.sealSession(conn, true, local, remote); err != nil{
+			conn.Close()
+			return "", err
+		}
 	}
+	conn.SetReadDeadline(time.Time{})
+	var remoteName, remoteType = remote.Name, remote.Type
 
-	var outgoingChan = make(chan Message, DefaultMessageQueueSize)
+	var outgoingChan = make(chan Message, endpoint.options.OutgoingQueueSize)
 	var finishChan = make(chan bool,1 )
-	log.Printf("<endpoint> remote service '%s' (type %d/ address %s) connected", remoteName, remoteType, target)
+	log.Printf("<endpoint> remote service '%s' (type %d/ address %s/ build %s) connected", remoteName, remoteType, target, remote.BuildVersion)
 	endpoint.connEventChan <- connEvent{ConnEventOpen, remoteName, remoteType,
-		address, port, true, session, outgoingChan, finishChan}
+		address, port, true, conn, outgoingChan, finishChan, remote.toPeer(address, conn), "", 0, 0}
 	//start routine
-	go sessionServeRoutine(remoteName, session, endpoint.incomingMessageChan, outgoingChan, finishChan, endpoint.connEventChan)
-	return nil
+	go sessionServeRoutine(remoteName, remoteType, conn, endpoint.incomingMessageChan, outgoingChan, finishChan, endpoint.connEventChan)
+	return remoteName, nil
 }
 
 func (endpoint *EndpointService) disconnectRemoteService(name string, entry connEntry) (err error) {
@@ -694,21 +1051,12 @@ func (endpoint *EndpointService) disconnectRemoteService(name string, entry conn
 	if err != nil{
 		return err
 	}
-	data, err := event.Serialize()
-	if err != nil{
-		return err
-	}
-	//send disconnect event
-	if _, err = entry.Session.Write(data);err != nil{
-		return err
-	}
+	//send disconnect event, session routine must finish even remote already closed
+	var writeError = entry.Session.WriteMessage(event)
 	if err = entry.Session.Close(); err != nil {
 		return err
 	}
-	const (
-		stopTimeout = 3*time.Second
-	)
-	timer := time.NewTimer(stopTimeout)
+	timer := time.NewTimer(endpoint.options.StopTimeout)
 	select {
 	case <- timer.C:
 		err = errors.New("wait session routine finish timeout")
@@ -716,174 +1064,170 @@ func (endpoint *EndpointService) disconnectRemoteService(name string, entry conn
 	case <- entry.FinishChan:
 		//finished
 	}
-	return nil
+	return writeError
 }
 
-func (endpoint *EndpointService) recoverStubService(){
-	if endpoint.recoveringStub{
-		log.Println("<endpoint> recovery already in processing")
-		return
-	}
-	endpoint.recoveringStub = true
-	const (
-		retryInterval = 3*time.Second
-		queryTimeout = 5*time.Second
-	)
-	defer func() {endpoint.recoveringStub = false}()
-	var err error
-	var echo sonar.Echo
-	if err != nil{
-		log.Printf("<endpoint> create recover pinger fail: %s", err.Error())
-		return
-	}
-	for endpoint.isRunning(){
-		time.Sleep(retryInterval)
-		if endpoint.stubAvailable{
-			log.Println("<endpoint> stub service already recovered")
-			break
-		}
-		log.Println("<endpoint> try recover stub service...")
-		pinger, err := sonar.CreatePinger(endpoint.groupAddress, endpoint.groupPort, endpoint.domain)
-		echo, err = pinger.Query(queryTimeout)
-		if err != nil{
-			log.Printf("<endpoint> recover fail: %s", err.Error())
-			continue
-		}
-		if 0 == len(echo.Services){
-			log.Println("<endpoint> requery success, but no stub available")
-			continue
-		}
-		var stub = echo.Services[0]
-		err = endpoint.connectRemoteService(stub.Address, stub.Port)
-		if err != nil{
-			log.Printf("<endpoint> connect stub %s:%d fail: %s", stub.Address, stub.Port, err.Error())
-			continue
-		}
-		log.Printf("<endpoint> new stub %s:%d recovered", stub.Address, stub.Port)
-		break
-	}
-
-}
-
-func selectAvailablePort(host string) (*kcp.Listener, int, error) {
+func (endpoint *EndpointService) selectAvailablePort(host string) (TransportListener, int, error) {
 	var address string
-	for port := ListenPortRangeStart; port < ListenPortRangeEnd; port++ {
+	var start, end = endpoint.options.PortRangeStart, endpoint.options.PortRangeEnd
+	for port := start; port < end; port++ {
 		address = fmt.Sprintf("%s:%d", host, port)
-		listener, err := kcp.ListenWithOptions(address, nil, DefaultDataShards, DefaultParityShards)
+		listener, err := endpoint.transport.Listen(address)
 		if err != nil {
 			continue
 		}
 		return listener, port, nil
 	}
-	return nil, 0, fmt.Errorf("no port available in range %d ~ %d", ListenPortRangeStart, ListenPortRangeEnd)
+	return nil, 0, fmt.Errorf("no port available in range %d ~ %d", start, end)
 }
 
-func receiveRemoteServiceInfo(session *kcp.UDPSession) (string, ServiceType, error) {
-	var buf = make([]byte, DefaultBufferSize)
-	//recv connect open
-	length, err := session.Read(buf)
-	if err != nil {
-		return "", 0, err
+func (endpoint *EndpointService) localHandshake() handshakeInfo {
+	return handshakeInfo{Name: endpoint.name, Type: endpoint.serviceType, ProtocolVersion: ProtocolVersion,
+		BuildVersion: endpoint.buildVersion, Capabilities: endpoint.capabilities,
+		Codecs: endpoint.wireCodecs, Compressions: endpoint.compressions}
+}
+
+//notifyServiceRejected surface handshake failure of dialing through OnServiceDisconnected
+func (endpoint *EndpointService) notifyServiceRejected(name string, t ServiceType, reason error) {
+	if _, exists := endpoint.connections.get(name); exists {
+		//keep state of live connection
+		log.Printf("<endpoint> ignore rejection by '%s' with live connection: %s", name, reason.Error())
+		return
 	}
-	msg, err := MessageFromJson(buf[:length])
+	msg, err := CreateJsonMessage(ServiceDisconnectedEvent)
 	if err != nil {
-		return "", 0, err
+		log.Printf("<endpoint> create message fail:%s", err.Error())
+		return
 	}
-	if msg.GetID() != ConnectionOpenedEvent {
-		return "", 0, fmt.Errorf("invalid message %d", msg.GetID())
+	msg.SetString(ParamKeyName, name)
+	msg.SetUInt(ParamKeyType, uint(t))
+	msg.SetBoolean(ParamKeyFlag, false)
+	msg.SetError(reason.Error())
+	if err = endpoint.SendToSelf(msg); err != nil {
+		log.Printf("<endpoint> notify rejected event fail: %s", err.Error())
 	}
-	serviceName, err := msg.GetString(ParamKeyName)
-	if err != nil {
-		return "", 0, errors.New("can not get service name")
+}
+
+//supportProtocol check protocol of published stub, legacy stub publish kcp only
+func (endpoint *EndpointService) supportProtocol(protocol string) bool{
+	if "" == protocol{
+		return endpoint.transport.Protocol() == TransportProtocolKCP
 	}
-	serviceType, err := msg.GetUInt(ParamKeyType)
-	if err != nil {
-		return "", 0, errors.New("can not get service type")
+	return endpoint.transport.Protocol() == protocol
+}
+
+func splitAddress(address net.Addr) (host string, port int, err error){
+	host, portString, err := net.SplitHostPort(address.String())
+	if err != nil{
+		return
 	}
-	return serviceName, ServiceType(serviceType), nil
+	port, err = strconv.Atoi(portString)
+	return
 }
 
-func sendServiceInfo(session *kcp.UDPSession, serviceName string, serviceType ServiceType) error {
-	notify, err := CreateJsonMessage(ConnectionOpenedEvent)
+func receiveRemoteServiceInfo(conn *frameConn) (info handshakeInfo, err error) {
+	//recv connect open
+	msg, err := conn.ReadHandshake()
 	if err != nil {
-		return err
+		return
 	}
-	notify.SetString(ParamKeyName, serviceName)
-	notify.SetUInt(ParamKeyType, uint(serviceType))
-	packet, err := notify.Serialize()
+	return parseHandshake(msg)
+}
+
+func sendServiceInfo(conn *frameConn, info handshakeInfo) error {
+	notify, err := info.toMessage()
 	if err != nil {
 		return err
 	}
-	_, err = session.Write(packet)
-	return err
+	return conn.WriteMessage(notify)
+}
+
+//incomingMessage carry origin recorded when message arrived, connection may be removed before message handled
+type incomingMessage struct {
+	Message Message
+	Remote  bool        //received from connection of remote service
+	Name    string      //name of remote service
+	Type    ServiceType //type of remote service
 }
 
-func sessionServeRoutine(remote string, session *kcp.UDPSession, incomingChan chan Message,
+//endpointEvents generated by endpoint itself, never accepted from remote service
+var endpointEvents = map[MessageID]bool{
+	ServiceAvailableEvent:     true,
+	ServiceConnectedEvent:     true,
+	ServiceDisconnectedEvent:  true,
+	ServiceReconnectEvent:     true,
+	ServiceLeaderChangedEvent: true,
+}
+
+func sessionServeRoutine(remote string, remoteType ServiceType, conn *frameConn, incomingChan chan incomingMessage,
 	outgoingChan chan Message, finishChan chan bool, eventChan chan connEvent) {
 	//log.Printf("<endpoint> receive routine for '%s' started", remote)
 	var gracefullyClose = false
-	var buf = make([]byte, DefaultBufferSize)
+	var closeReason string
 	var sendStopChan = make(chan bool, 1)
 	var sendExitChan = make(chan bool, 1)
-	go sessionOutgoingRoutine(remote, session, outgoingChan, sendStopChan, sendExitChan)
-	var bufStart, bufEnd = 0, 0
+	go sessionOutgoingRoutine(remote, conn, outgoingChan, sendStopChan, sendExitChan)
 	for {
-		//recv connect open
-		count, err := session.Read(buf[bufStart:])
+		msg, err := conn.ReadMessage()
 		if err != nil {
-			log.Printf("<endpoint> warning: connection lost from %s : %s", remote, err.Error())
+			if _, corrupted := err.(*FrameError); corrupted {
+				//reset connection, remaining data can not be synchronized
+				log.Printf("<endpoint> warning: reset connection from %s : %s", remote, err.Error())
+				conn.Close()
+			} else {
+				log.Printf("<endpoint> warning: connection lost from %s : %s", remote, err.Error())
+			}
 			break
 		}
-		bufEnd = bufStart + count
-		if bufEnd > DefaultBufferSize{
-			bufStart = 0
-			log.Printf("<endpoint> warning: discard cached data because buffer overflow from %s", remote)
-			continue
-		}
-		msg, err := MessageFromJson(buf[:bufEnd])
-		if err != nil {
-			//need cache
-			bufStart = bufEnd
-			log.Printf("<endpoint> warning: cache %d byte(s) from %s", count, remote)
-			//log.Printf("<endpoint> warning: parse message fail: %s, data(%d byte(s)): %s", err.Error(), count, buf[:count])
-			continue
-		}
-		bufStart = 0
 		if msg.GetID() == ConnectionKeepAliveEvent {
-			eventChan <- connEvent{Event: ConnEventHeartBeat, Name: remote}
+			var event = connEvent{Event: ConnEventHeartBeat, Name: remote}
+			if sequence, rtt, isEcho := parseEcho(msg, time.Now()); isEcho{
+				event.Sequence, event.RTT = sequence, rtt
+			}else if echo, required := echoKeepAlive(msg); required{
+				select {
+				case outgoingChan <- echo:
+				default:
+					//never blocked by slow service
+				}
+			}
+			eventChan <- event
 			continue
 		}else if msg.GetID() == ConnectionClosedEvent{
-			gracefullyClose = true
-			log.Printf("<endpoint> connection closed by remote endpoint '%s'", remote)
+			closeReason = msg.GetError()
+			if "" != closeReason{
+				log.Printf("<endpoint> connection closed by remote endpoint '%s': %s", remote, closeReason)
+			}else{
+				gracefullyClose = true
+				log.Printf("<endpoint> connection closed by remote endpoint '%s'", remote)
+			}
 			break
 		}
+		if endpointEvents[msg.GetID()] {
+			log.Printf("<endpoint> warning: drop event %08X forged by remote endpoint '%s'", uint32(msg.GetID()), remote)
+			continue
+		}
 		if "" == msg.GetSender() {
 			msg.SetSender(remote)
 		}
-		incomingChan <- msg
+		incomingChan <- incomingMessage{msg, true, remote, remoteType}
 	}
 	//closing outgoing routine
 	sendStopChan <- true
 	<-sendExitChan
 	//notify closed
-	eventChan <- connEvent{Event: ConnEventClose, Name: remote, Gracefully: gracefullyClose}
+	eventChan <- connEvent{Event: ConnEventClose, Name: remote, Gracefully: gracefullyClose, Reason: closeReason}
 	finishChan <- true
 	//log.Printf("<endpoint> receive routine for '%s' stopped", remote)
 }
 
-func sessionOutgoingRoutine(remote string, session *kcp.UDPSession, outgoingChan chan Message,
+func sessionOutgoingRoutine(remote string, conn *frameConn, outgoingChan chan Message,
 	notify, stopped chan bool) {
 	//log.Printf("<endpoint> send routine for '%s' started", remote)
 	var exitFlag = false
 	for !exitFlag {
 		select {
 		case msg := <-outgoingChan:
-			data, err := msg.Serialize()
-			if err != nil {
-				log.Printf("<endpoint> serial outgoing message fail: %s", err.Error())
-				break
-			}
-			if _, err = session.Write(data); err != nil {
+			if err := conn.WriteMessage(msg); err != nil {
 				log.Printf("<endpoint> outgoing message to '%s' fail: %s", remote, err.Error())
 				break
 			}
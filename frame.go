@@ -0,0 +1,344 @@
+package framework
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//frame layout: | magic(2) | version(1) | flags(1) | payload length(4) | payload | seal(32, sealed session only) |
+//flags: lower 4 bits for codec, higher 4 bits for compression
+const (
+	FrameMagic          = 0x4E46 //"NF"
+	FrameVersion        = 1
+	FrameHeaderSize     = 8
+	MaxFramePayload     = 32 << 20 //32MiB
+	frameReadBufSize    = 64 << 10
+	frameCodecMask      = 0x0F
+	frameCompressOffset = 4
+	//payload smaller than threshold always sent uncompressed
+	CompressThreshold = 1 << 10
+	frameSealSize     = sha256.Size
+)
+
+type CompressID uint8
+
+const (
+	CompressNone CompressID = iota
+	CompressFlate
+)
+
+type FrameError struct {
+	Reason string
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("corrupt frame: %s", e.Reason)
+}
+
+func encodeFrameHeader(header []byte, flags uint8, length int) {
+	binary.BigEndian.PutUint16(header[0:2], FrameMagic)
+	header[2] = FrameVersion
+	header[3] = flags
+	binary.BigEndian.PutUint32(header[4:FrameHeaderSize], uint32(length))
+}
+
+func encodeFrame(flags uint8, payload []byte) ([]byte, error) {
+	if len(payload) > MaxFramePayload {
+		return nil, fmt.Errorf("payload size %d exceeds limit %d", len(payload), MaxFramePayload)
+	}
+	var frame = make([]byte, FrameHeaderSize+len(payload))
+	encodeFrameHeader(frame, flags, len(payload))
+	copy(frame[FrameHeaderSize:], payload)
+	return frame, nil
+}
+
+//frameSeal authenticate frames of a session with keys derived in handshake,
+//sequence of each direction covered so that frames can not be injected, replayed or reordered
+type frameSeal struct {
+	lock            sync.Mutex
+	sendKey         []byte
+	receiveKey      []byte
+	sendSequence    uint64
+	receiveSequence uint64
+}
+
+//computeSeal HMAC-SHA256 over sequence and the whole frame
+func computeSeal(key []byte, sequence uint64, header, payload []byte) []byte {
+	var mac = hmac.New(sha256.New, key)
+	var buf = make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, sequence)
+	mac.Write(buf)
+	mac.Write(header)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func decodeFrame(reader io.Reader) (flags uint8, payload []byte, err error) {
+	var header = make([]byte, FrameHeaderSize)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return
+	}
+	if magic := binary.BigEndian.Uint16(header[0:2]); magic != FrameMagic {
+		err = &FrameError{fmt.Sprintf("invalid magic %04X", magic)}
+		return
+	}
+	if version := header[2]; version != FrameVersion {
+		err = &FrameError{fmt.Sprintf("unsupported version %d", version)}
+		return
+	}
+	flags = header[3]
+	var length = binary.BigEndian.Uint32(header[4:FrameHeaderSize])
+	if length > MaxFramePayload {
+		err = &FrameError{fmt.Sprintf("payload size %d exceeds limit %d", length, MaxFramePayload)}
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(reader, payload); err != nil {
+		return
+	}
+	return flags, payload, nil
+}
+
+func compressPayload(method CompressID, payload []byte) ([]byte, error) {
+	switch method {
+	case CompressNone:
+		return payload, nil
+	case CompressFlate:
+		var buffer bytes.Buffer
+		writer, err := flate.NewWriter(&buffer, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = writer.Write(payload); err != nil {
+			return nil, err
+		}
+		if err = writer.Close(); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", method)
+	}
+}
+
+func decompressPayload(method CompressID, payload []byte) ([]byte, error) {
+	switch method {
+	case CompressNone:
+		return payload, nil
+	case CompressFlate:
+		var reader = flate.NewReader(bytes.NewReader(payload))
+		defer reader.Close()
+		//never inflate beyond frame limit
+		data, err := io.ReadAll(io.LimitReader(reader, MaxFramePayload+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > MaxFramePayload {
+			return nil, fmt.Errorf("inflated payload exceeds limit %d", MaxFramePayload)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", method)
+	}
+}
+
+//frameConn wraps a session with length-prefixed message framing,
+//codec and compression of incoming frame are declared by its flags.
+//legacy connection exchanges bare json messages with endpoints before framing
+type frameConn struct {
+	session  TransportConn
+	reader   *bufio.Reader
+	decoder  *json.Decoder
+	legacy   bool
+	codec    MessageCodec
+	compress CompressID
+	traffic  *trafficCounter
+	seal     *frameSeal //nil for session not sealed
+}
+
+//trafficCounter of a connection, handshake included
+type trafficCounter struct {
+	messagesSent     uint64
+	messagesReceived uint64
+	bytesSent        uint64
+	bytesReceived    uint64
+}
+
+//countingReader count bytes received from session
+type countingReader struct {
+	source  io.Reader
+	traffic *trafficCounter
+}
+
+func (reader *countingReader) Read(buf []byte) (int, error) {
+	count, err := reader.source.Read(buf)
+	atomic.AddUint64(&reader.traffic.bytesReceived, uint64(count))
+	return count, err
+}
+
+func newFrameConn(session TransportConn) *frameConn {
+	var traffic = &trafficCounter{}
+	return &frameConn{session: session, reader: bufio.NewReaderSize(&countingReader{session, traffic}, frameReadBufSize),
+		codec: &JsonCodec{}, compress: CompressNone, traffic: traffic}
+}
+
+func (conn *frameConn) write(data []byte) error {
+	count, err := conn.session.Write(data)
+	atomic.AddUint64(&conn.traffic.bytesSent, uint64(count))
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&conn.traffic.messagesSent, 1)
+	return nil
+}
+
+//WriteMessage serialize and send a message in a single write, safe for concurrent callers
+func (conn *frameConn) WriteMessage(msg Message) error {
+	if conn.legacy {
+		return conn.writeLegacyMessage(msg)
+	}
+	payload, err := conn.codec.Encode(msg)
+	if err != nil {
+		return err
+	}
+	var flags = uint8(conn.codec.ID()) & frameCodecMask
+	if conn.compress != CompressNone && len(payload) >= CompressThreshold {
+		if payload, err = compressPayload(conn.compress, payload); err != nil {
+			return err
+		}
+		flags |= uint8(conn.compress) << frameCompressOffset
+	}
+	frame, err := encodeFrame(flags, payload)
+	if err != nil {
+		return err
+	}
+	if nil != conn.seal {
+		return conn.writeSealed(frame)
+	}
+	return conn.write(frame)
+}
+
+//writeSealed append seal with next sequence, sequence must be consumed in order of writing
+func (conn *frameConn) writeSealed(frame []byte) error {
+	var seal = conn.seal
+	seal.lock.Lock()
+	defer seal.lock.Unlock()
+	frame = append(frame, computeSeal(seal.sendKey, seal.sendSequence, frame[:FrameHeaderSize], frame[FrameHeaderSize:])...)
+	seal.sendSequence++
+	return conn.write(frame)
+}
+
+//verifySeal read and check seal following payload
+func (conn *frameConn) verifySeal(flags uint8, payload []byte) error {
+	var received = make([]byte, frameSealSize)
+	if _, err := io.ReadFull(conn.reader, received); err != nil {
+		return err
+	}
+	var seal = conn.seal
+	var header = make([]byte, FrameHeaderSize)
+	encodeFrameHeader(header, flags, len(payload))
+	var expected = computeSeal(seal.receiveKey, seal.receiveSequence, header, payload)
+	seal.receiveSequence++
+	if !hmac.Equal(received, expected) {
+		return &FrameError{"invalid seal"}
+	}
+	return nil
+}
+
+//Seal authenticate all frames after current one, both endpoints must seal at the same frame
+func (conn *frameConn) Seal(sendKey, receiveKey []byte) error {
+	if conn.legacy {
+		return errors.New("legacy connection can not be sealed")
+	}
+	conn.seal = &frameSeal{sendKey: sendKey, receiveKey: receiveKey}
+	return nil
+}
+
+func (conn *frameConn) writeLegacyMessage(msg Message) error {
+	data, err := (&JsonCodec{}).Encode(msg)
+	if err != nil {
+		return err
+	}
+	return conn.write(data)
+}
+
+func (conn *frameConn) ReadMessage() (Message, error) {
+	if conn.legacy {
+		return conn.readLegacyMessage()
+	}
+	flags, payload, err := decodeFrame(conn.reader)
+	if err != nil {
+		return nil, err
+	}
+	if nil != conn.seal {
+		if err = conn.verifySeal(flags, payload); err != nil {
+			return nil, err
+		}
+	}
+	if payload, err = decompressPayload(CompressID(flags>>frameCompressOffset), payload); err != nil {
+		return nil, &FrameError{fmt.Sprintf("invalid compressed payload: %s", err.Error())}
+	}
+	msg, err := DecodeMessage(CodecID(flags&frameCodecMask), payload)
+	if err != nil {
+		return nil, &FrameError{fmt.Sprintf("invalid payload: %s", err.Error())}
+	}
+	atomic.AddUint64(&conn.traffic.messagesReceived, 1)
+	return msg, nil
+}
+
+func (conn *frameConn) readLegacyMessage() (Message, error) {
+	if nil == conn.decoder {
+		conn.decoder = json.NewDecoder(conn.reader)
+	}
+	var msg JsonMessage
+	if err := conn.decoder.Decode(&msg); err != nil {
+		if _, isSyntaxError := err.(*json.SyntaxError); isSyntaxError {
+			return nil, &FrameError{fmt.Sprintf("invalid json: %s", err.Error())}
+		}
+		return nil, err
+	}
+	atomic.AddUint64(&conn.traffic.messagesReceived, 1)
+	return &msg, nil
+}
+
+//ReadHandshake read the first message from remote endpoint, bare json switches connection to legacy mode
+func (conn *frameConn) ReadHandshake() (Message, error) {
+	head, err := conn.reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	conn.legacy = '{' == head[0]
+	return conn.ReadMessage()
+}
+
+//EnableFraming switch a legacy connection to framed mode, data buffered by json decoder is preserved
+func (conn *frameConn) EnableFraming() {
+	if !conn.legacy {
+		return
+	}
+	conn.legacy = false
+	if nil != conn.decoder {
+		conn.reader = bufio.NewReaderSize(io.MultiReader(conn.decoder.Buffered(), conn.reader), frameReadBufSize)
+		conn.decoder = nil
+	}
+}
+
+//SetReadDeadline limit waiting time of ReadMessage, zero value for no limit
+func (conn *frameConn) SetReadDeadline(t time.Time) error {
+	return conn.session.SetReadDeadline(t)
+}
+
+func (conn *frameConn) Close() error {
+	return conn.session.Close()
+}
@@ -0,0 +1,224 @@
+package framework
+
+import (
+	"bytes"
+	"github.com/xtaci/kcp-go"
+	"testing"
+)
+
+func Test_FrameSequence(t *testing.T) {
+	const (
+		frameCount = 5
+	)
+	var buffer bytes.Buffer
+	var sent [][]byte
+	for i := 0; i < frameCount; i++ {
+		origin, err := generateMessage()
+		if err != nil {
+			t.Fatalf("generate message fail: %s", err.Error())
+		}
+		generateStringParam(origin, 3)
+		payload, err := origin.Serialize()
+		if err != nil {
+			t.Fatalf("serialize fail: %s", err.Error())
+		}
+		frame, err := encodeFrame(0, payload)
+		if err != nil {
+			t.Fatalf("encode frame fail: %s", err.Error())
+		}
+		buffer.Write(frame)
+		sent = append(sent, payload)
+	}
+	//all frames arrived in one read
+	for i := 0; i < frameCount; i++ {
+		_, payload, err := decodeFrame(&buffer)
+		if err != nil {
+			t.Fatalf("decode %dth frame fail: %s", i, err.Error())
+		}
+		if !bytes.Equal(payload, sent[i]) {
+			t.Fatalf("%dth frame corrupted", i)
+		}
+	}
+	if 0 != buffer.Len() {
+		t.Fatalf("%d byte(s) remained after decode", buffer.Len())
+	}
+}
+
+func Test_FrameLargePayload(t *testing.T) {
+	var payload = make([]byte, DefaultBufferSize+1024)
+	generator.Read(payload)
+	frame, err := encodeFrame(0, payload)
+	if err != nil {
+		t.Fatalf("encode frame fail: %s", err.Error())
+	}
+	_, decoded, err := decodeFrame(bytes.NewReader(frame))
+	if err != nil {
+		t.Fatalf("decode frame fail: %s", err.Error())
+	}
+	if !bytes.Equal(payload, decoded) {
+		t.Fatal("large payload corrupted")
+	}
+}
+
+func Test_FrameCorrupted(t *testing.T) {
+	frame, err := encodeFrame(0, []byte("{}"))
+	if err != nil {
+		t.Fatalf("encode frame fail: %s", err.Error())
+	}
+	frame[0] = '{'
+	_, _, err = decodeFrame(bytes.NewReader(frame))
+	if err == nil {
+		t.Fatal("corrupted magic not detected")
+	}
+	if _, ok := err.(*FrameError); !ok {
+		t.Fatalf("unexpected error type: %s", err.Error())
+	}
+	t.Logf("corrupted frame rejected: %s", err.Error())
+}
+
+func createSessionPair(t *testing.T, serverCrypt, clientCrypt kcp.BlockCrypt) (client, server *kcp.UDPSession) {
+	listener, err := kcp.ListenWithOptions("127.0.0.1:0", serverCrypt, DefaultDataShards, DefaultParityShards)
+	if err != nil {
+		t.Fatalf("listen fail: %s", err.Error())
+	}
+	t.Cleanup(func() { listener.Close() })
+	client, err = kcp.DialWithOptions(listener.Addr().String(), clientCrypt, DefaultDataShards, DefaultParityShards)
+	if err != nil {
+		t.Fatalf("dial fail: %s", err.Error())
+	}
+	//kcp session accepted after first packet arrived
+	if _, err = client.Write([]byte("{}")); err != nil {
+		t.Fatalf("write fail: %s", err.Error())
+	}
+	if server, err = listener.AcceptKCP(); err != nil {
+		t.Fatalf("accept fail: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func Test_FrameLegacyHandshake(t *testing.T) {
+	client, server := createSessionPair(t, nil, nil)
+	var conn = newFrameConn(server)
+	//first empty object written by createSessionPair
+	if _, err := conn.ReadHandshake(); err != nil {
+		t.Fatalf("read legacy handshake fail: %s", err.Error())
+	}
+	if !conn.legacy {
+		t.Fatal("legacy json not detected")
+	}
+	//legacy endpoint may pack two messages in one write
+	first, _ := CreateJsonMessage(ConnectionKeepAliveEvent)
+	second, _ := CreateJsonMessage(ConnectionClosedEvent)
+	data1, _ := first.Serialize()
+	data2, _ := second.Serialize()
+	if _, err := client.Write(append(data1, data2...)); err != nil {
+		t.Fatalf("write fail: %s", err.Error())
+	}
+	for _, expected := range []MessageID{ConnectionKeepAliveEvent, ConnectionClosedEvent} {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read legacy message fail: %s", err.Error())
+		}
+		if msg.GetID() != expected {
+			t.Fatalf("unexpected message %08X", msg.GetID())
+		}
+	}
+}
+
+func Test_FrameNegotiatedCodec(t *testing.T) {
+	client, server := createSessionPair(t, nil, nil)
+	var receiver = newFrameConn(server)
+	var sender = newFrameConn(client)
+	//switch to framing right after the legacy json
+	if _, err := receiver.ReadHandshake(); err != nil {
+		t.Fatalf("read handshake fail: %s", err.Error())
+	}
+	receiver.EnableFraming()
+	if err := sender.useCodec(CodecBinary, CompressFlate); err != nil {
+		t.Fatalf("select codec fail: %s", err.Error())
+	}
+	origin, err := generateMessage()
+	if err != nil {
+		t.Fatalf("generate message fail: %s", err.Error())
+	}
+	//large enough to be compressed
+	generateStringArrayParam(origin, 100)
+	if err = sender.WriteMessage(origin); err != nil {
+		t.Fatalf("write message fail: %s", err.Error())
+	}
+	received, err := receiver.ReadMessage()
+	if err != nil {
+		t.Fatalf("read message fail: %s", err.Error())
+	}
+	if _, isBinary := received.(*BinaryMessage); !isBinary {
+		t.Fatal("message not decoded by binary codec")
+	}
+	identical, err := isIdentical(origin, CloneJsonMessage(received), nil)
+	if err != nil {
+		t.Fatalf("compare message fail: %s", err.Error())
+	}
+	if !identical {
+		t.Fatal("message corrupted")
+	}
+}
+
+func Test_FrameSealed(t *testing.T) {
+	var dialerKey, acceptorKey = []byte("dialer key"), []byte("acceptor key")
+	var send = func(conn *frameConn) {
+		msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+		if err := conn.WriteMessage(msg); err != nil {
+			t.Fatalf("write message fail: %s", err.Error())
+		}
+	}
+	var createPair = func() (client *kcp.UDPSession, sender, receiver *frameConn) {
+		client, server := createSessionPair(t, nil, nil)
+		sender, receiver = newFrameConn(client), newFrameConn(server)
+		//first empty object written by createSessionPair
+		if _, err := receiver.ReadHandshake(); err != nil {
+			t.Fatalf("read handshake fail: %s", err.Error())
+		}
+		receiver.EnableFraming()
+		if err := sender.Seal(dialerKey, acceptorKey); err != nil {
+			t.Fatalf("seal sender fail: %s", err.Error())
+		}
+		if err := receiver.Seal(acceptorKey, dialerKey); err != nil {
+			t.Fatalf("seal receiver fail: %s", err.Error())
+		}
+		return client, sender, receiver
+	}
+	var expectCorrupted = func(receiver *frameConn, description string) {
+		_, err := receiver.ReadMessage()
+		if err == nil {
+			t.Fatalf("%s accepted", description)
+		}
+		if _, ok := err.(*FrameError); !ok {
+			t.Fatalf("unexpected error type: %s", err.Error())
+		}
+	}
+	client, sender, receiver := createPair()
+	send(sender)
+	if msg, err := receiver.ReadMessage(); err != nil || ComputePoolReadyEvent != msg.GetID() {
+		t.Fatalf("read sealed message fail: %v", err)
+	}
+	//injected frame without valid seal
+	payload, _ := (&JsonCodec{}).Encode(&JsonMessage{ID: ComputePoolReadyEvent})
+	frame, _ := encodeFrame(uint8(CodecJson), payload)
+	if _, err := client.Write(append(frame, make([]byte, frameSealSize)...)); err != nil {
+		t.Fatalf("write fail: %s", err.Error())
+	}
+	expectCorrupted(receiver, "injected frame")
+
+	//replayed frame
+	_, sender, receiver = createPair()
+	send(sender)
+	sender.seal.sendSequence = 0
+	send(sender)
+	if _, err := receiver.ReadMessage(); err != nil {
+		t.Fatalf("read sealed message fail: %s", err.Error())
+	}
+	expectCorrupted(receiver, "replayed frame")
+}
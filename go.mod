@@ -7,6 +7,7 @@ require (
 	github.com/project-nano/sonar v0.0.0-20190628085230-df7942628d6f
 	github.com/sevlyar/go-daemon v0.1.6
 	github.com/xtaci/kcp-go v5.4.20+incompatible
+	golang.org/x/crypto v0.13.0
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.5 // indirect
 	github.com/klauspost/reedsolomon v1.11.8 // indirect
@@ -15,7 +16,6 @@ require (
 	github.com/templexxx/xor v0.0.0-20191217153810-f85b25db303b // indirect
 	github.com/tjfoc/gmsm v1.4.1 // indirect
 	github.com/xtaci/lossyconn v0.0.0-20200209145036-adba10fffc37 // indirect
-	golang.org/x/crypto v0.13.0 // indirect
 	golang.org/x/net v0.15.0 // indirect
 	golang.org/x/sys v0.12.0 // indirect
 )
@@ -0,0 +1,276 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	//ProtocolVersion increase when wire protocol changed
+	ProtocolVersion = 2
+	//endpoint before framing never declare version
+	LegacyProtocolVersion = 1
+	FrameworkVersion      = "1.1.0"
+)
+
+//capabilities advertised in handshake
+const (
+	CapabilityFraming  = "framing"
+	CapabilityCompress = "compress"
+	CapabilityEcho     = "echo" //echo keep alive for latency measurement
+)
+
+//PeerInfo describe a connected remote endpoint
+type PeerInfo struct {
+	Name            string
+	Type            ServiceType
+	Address         string
+	ProtocolVersion uint
+	BuildVersion    string
+	Capabilities    []string
+	Codec           CodecID
+	Compress        CompressID
+	Legacy          bool
+}
+
+func (info PeerInfo) HasCapability(capability string) bool {
+	return containsString(info.Capabilities, capability)
+}
+
+//errStubStandby rejected by standby stub, dialer should try the next stub
+var errStubStandby = errors.New("stub in standby")
+
+//RejectedError returned when remote endpoint refuses the connection during handshake
+type RejectedError struct {
+	Name    string
+	Type    ServiceType
+	Reason  string
+	Standby bool //rejected by standby stub, never connected
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected by '%s': %s", e.Name, e.Reason)
+}
+
+//handshakeInfo carried by ConnectionOpenedEvent
+type handshakeInfo struct {
+	Name            string
+	Type            ServiceType
+	ProtocolVersion uint
+	BuildVersion    string
+	Capabilities    []string
+	Codecs          []CodecID
+	Compressions    []CompressID
+	//authentication challenge and proof, only when domain key configured
+	Nonce           string
+	Proof           string
+	//selected by the accepting side, only available in reply
+	Negotiated bool
+	Codec      CodecID
+	Compress   CompressID
+}
+
+func (info handshakeInfo) toMessage() (Message, error) {
+	msg, err := CreateJsonMessage(ConnectionOpenedEvent)
+	if err != nil {
+		return nil, err
+	}
+	msg.SetString(ParamKeyName, info.Name)
+	msg.SetUInt(ParamKeyType, uint(info.Type))
+	msg.SetUInt(ParamKeyVersion, info.ProtocolVersion)
+	msg.SetString(ParamKeyVersion, info.BuildVersion)
+	if 0 != len(info.Capabilities) {
+		msg.SetStringArray(ParamKeyCapability, info.Capabilities)
+	}
+	if 0 != len(info.Codecs) {
+		var codecs []uint64
+		for _, codec := range info.Codecs {
+			codecs = append(codecs, uint64(codec))
+		}
+		msg.SetUIntArray(ParamKeyCodec, codecs)
+	}
+	if 0 != len(info.Compressions) {
+		var compressions []uint64
+		for _, compress := range info.Compressions {
+			compressions = append(compressions, uint64(compress))
+		}
+		msg.SetUIntArray(ParamKeyCompress, compressions)
+	}
+	if "" != info.Nonce {
+		msg.SetString(ParamKeyNonce, info.Nonce)
+	}
+	if "" != info.Proof {
+		msg.SetString(ParamKeyProof, info.Proof)
+	}
+	if info.Negotiated {
+		msg.SetUInt(ParamKeyCodec, uint(info.Codec))
+		msg.SetUInt(ParamKeyCompress, uint(info.Compress))
+	}
+	return msg, nil
+}
+
+func parseHandshake(msg Message) (info handshakeInfo, err error) {
+	if msg.GetID() == ConnectionClosedEvent {
+		var rejected = &RejectedError{Reason: msg.GetError()}
+		rejected.Name, _ = msg.GetString(ParamKeyName)
+		rejected.Standby, _ = msg.GetBoolean(ParamKeyFlag)
+		if serviceType, err := msg.GetUInt(ParamKeyType); err == nil {
+			rejected.Type = ServiceType(serviceType)
+		}
+		err = rejected
+		return
+	}
+	if msg.GetID() != ConnectionOpenedEvent {
+		err = fmt.Errorf("invalid message %d", msg.GetID())
+		return
+	}
+	if info.Name, err = msg.GetString(ParamKeyName); err != nil {
+		err = errors.New("can not get service name")
+		return
+	}
+	serviceType, err := msg.GetUInt(ParamKeyType)
+	if err != nil {
+		err = errors.New("can not get service type")
+		return
+	}
+	info.Type = ServiceType(serviceType)
+	if version, err := msg.GetUInt(ParamKeyVersion); err == nil {
+		info.ProtocolVersion = version
+	} else {
+		info.ProtocolVersion = LegacyProtocolVersion
+	}
+	info.BuildVersion, _ = msg.GetString(ParamKeyVersion)
+	info.Capabilities, _ = msg.GetStringArray(ParamKeyCapability)
+	info.Nonce, _ = msg.GetString(ParamKeyNonce)
+	info.Proof, _ = msg.GetString(ParamKeyProof)
+	//legacy endpoint only speaks json without compression
+	if codecs, err := msg.GetUIntArray(ParamKeyCodec); err == nil {
+		for _, codec := range codecs {
+			info.Codecs = append(info.Codecs, CodecID(codec))
+		}
+	}
+	if compressions, err := msg.GetUIntArray(ParamKeyCompress); err == nil {
+		for _, compress := range compressions {
+			info.Compressions = append(info.Compressions, CompressID(compress))
+		}
+	}
+	if codec, err := msg.GetUInt(ParamKeyCodec); err == nil {
+		info.Negotiated = true
+		info.Codec = CodecID(codec)
+		if compress, err := msg.GetUInt(ParamKeyCompress); err == nil {
+			info.Compress = CompressID(compress)
+		}
+	}
+	return info, nil
+}
+
+//checkCompatible verify remote endpoint against local requirement
+func (info handshakeInfo) checkCompatible(minVersion uint, required []string) error {
+	if info.ProtocolVersion < minVersion {
+		return fmt.Errorf("protocol version %d of '%s' is lower than required %d", info.ProtocolVersion, info.Name, minVersion)
+	}
+	for _, capability := range required {
+		if !containsString(info.Capabilities, capability) {
+			return fmt.Errorf("'%s' (build %s) lacks required capability '%s'", info.Name, info.BuildVersion, capability)
+		}
+	}
+	return nil
+}
+
+func (info handshakeInfo) toPeer(address string, conn *frameConn) PeerInfo {
+	return PeerInfo{Name: info.Name, Type: info.Type, Address: address, ProtocolVersion: info.ProtocolVersion,
+		BuildVersion: info.BuildVersion, Capabilities: info.Capabilities, Codec: conn.codec.ID(),
+		Compress: conn.compress, Legacy: conn.legacy}
+}
+
+//rejectConnection notify remote endpoint the reason before closing
+func rejectConnection(conn *frameConn, local handshakeInfo, reason error) error {
+	defer conn.Close()
+	msg, err := CreateJsonMessage(ConnectionClosedEvent)
+	if err != nil {
+		return err
+	}
+	msg.SetString(ParamKeyName, local.Name)
+	msg.SetUInt(ParamKeyType, uint(local.Type))
+	msg.SetError(reason.Error())
+	if errStubStandby == reason {
+		msg.SetBoolean(ParamKeyFlag, true)
+	}
+	return conn.WriteMessage(msg)
+}
+
+//supportFraming means remote endpoint accept framed message after handshake
+func (info handshakeInfo) supportFraming() bool {
+	return 0 != len(info.Codecs)
+}
+
+//negotiate select the first local preferred codec/compression also supported by remote
+func (info *handshakeInfo) negotiate(remote handshakeInfo) {
+	info.Negotiated = true
+	info.Codec = CodecJson
+	info.Compress = CompressNone
+	for _, local := range info.Codecs {
+		if containsCodec(remote.Codecs, local) {
+			info.Codec = local
+			break
+		}
+	}
+	for _, local := range info.Compressions {
+		if containsCompress(remote.Compressions, local) {
+			info.Compress = local
+			break
+		}
+	}
+}
+
+func containsCodec(list []CodecID, target CodecID) bool {
+	for _, codec := range list {
+		if codec == target {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, target string) bool {
+	for _, value := range list {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCompress(list []CompressID, target CompressID) bool {
+	for _, compress := range list {
+		if compress == target {
+			return true
+		}
+	}
+	return false
+}
+
+//applySelection configure connection with codec/compression selected by remote
+func (conn *frameConn) applySelection(local, remote handshakeInfo) error {
+	if !remote.Negotiated {
+		//legacy
+		return nil
+	}
+	if !containsCodec(local.Codecs, remote.Codec) {
+		return fmt.Errorf("unsupported codec %d selected by '%s'", remote.Codec, remote.Name)
+	}
+	if CompressNone != remote.Compress && !containsCompress(local.Compressions, remote.Compress) {
+		return fmt.Errorf("unsupported compression %d selected by '%s'", remote.Compress, remote.Name)
+	}
+	return conn.useCodec(remote.Codec, remote.Compress)
+}
+
+func (conn *frameConn) useCodec(id CodecID, compress CompressID) error {
+	codec, err := GetCodec(id)
+	if err != nil {
+		return err
+	}
+	conn.codec = codec
+	conn.compress = compress
+	return nil
+}
@@ -0,0 +1,194 @@
+package framework
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_HandshakeNegotiate(t *testing.T) {
+	var local = handshakeInfo{Name: "core", Type: ServiceTypeCore, ProtocolVersion: ProtocolVersion,
+		BuildVersion: FrameworkVersion, Capabilities: defaultCapabilities(),
+		Codecs: defaultWireCodecs(), Compressions: defaultCompressions()}
+	var remote = handshakeInfo{Name: "cell", Type: ServiceTypeCell, ProtocolVersion: ProtocolVersion,
+		Codecs: []CodecID{CodecJson}, Compressions: []CompressID{CompressNone}}
+	msg, err := remote.toMessage()
+	if err != nil {
+		t.Fatalf("build handshake fail: %s", err.Error())
+	}
+	parsed, err := parseHandshake(msg)
+	if err != nil {
+		t.Fatalf("parse handshake fail: %s", err.Error())
+	}
+	if !parsed.supportFraming() {
+		t.Fatal("framing not recognized")
+	}
+	local.negotiate(parsed)
+	if local.Codec != CodecJson || local.Compress != CompressNone {
+		t.Fatalf("unexpected selection: codec %d, compress %d", local.Codec, local.Compress)
+	}
+	reply, err := local.toMessage()
+	if err != nil {
+		t.Fatalf("build reply fail: %s", err.Error())
+	}
+	selection, err := parseHandshake(reply)
+	if err != nil {
+		t.Fatalf("parse reply fail: %s", err.Error())
+	}
+	if !selection.Negotiated || selection.Codec != CodecJson {
+		t.Fatal("selection lost in reply")
+	}
+	if selection.BuildVersion != FrameworkVersion {
+		t.Fatalf("unexpected build version '%s'", selection.BuildVersion)
+	}
+}
+
+func Test_HandshakeLegacyPeer(t *testing.T) {
+	//endpoint before framing only carries name and type
+	msg, _ := CreateJsonMessage(ConnectionOpenedEvent)
+	msg.SetString(ParamKeyName, "legacy")
+	msg.SetUInt(ParamKeyType, ServiceTypeCell)
+	info, err := parseHandshake(msg)
+	if err != nil {
+		t.Fatalf("parse legacy handshake fail: %s", err.Error())
+	}
+	if info.supportFraming() {
+		t.Fatal("legacy peer should not support framing")
+	}
+	if info.ProtocolVersion != LegacyProtocolVersion {
+		t.Fatalf("unexpected legacy version %d", info.ProtocolVersion)
+	}
+	if err = info.checkCompatible(LegacyProtocolVersion, nil); err != nil {
+		t.Fatalf("legacy peer rejected by default: %s", err.Error())
+	}
+	if err = info.checkCompatible(ProtocolVersion, nil); err == nil {
+		t.Fatal("legacy peer accepted with higher requirement")
+	}
+	t.Logf("legacy peer rejected: %s", err.Error())
+	if err = info.checkCompatible(LegacyProtocolVersion, []string{CapabilityCompress}); err == nil {
+		t.Fatal("peer accepted without required capability")
+	}
+	t.Logf("peer rejected: %s", err.Error())
+}
+
+func Test_HandshakeRejected(t *testing.T) {
+	msg, _ := CreateJsonMessage(ConnectionClosedEvent)
+	msg.SetString(ParamKeyName, "core")
+	msg.SetUInt(ParamKeyType, ServiceTypeCore)
+	msg.SetError("protocol version too low")
+	_, err := parseHandshake(msg)
+	rejected, isRejected := err.(*RejectedError)
+	if !isRejected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rejected.Name != "core" || rejected.Reason != "protocol version too low" {
+		t.Fatalf("unexpected rejection: %s", rejected.Error())
+	}
+}
+
+//dialHandshake open a raw connection to target and return its reply of handshake
+func dialHandshake(network *MemoryNetwork, target *memoryPeer, info handshakeInfo) (reply handshakeInfo, err error) {
+	var transport = &memoryTransport{network}
+	session, err := transport.Dial(fmt.Sprintf("%s:%d", target.GetListenAddress(), target.GetListenPort()))
+	if err != nil {
+		return
+	}
+	var conn = newFrameConn(session)
+	defer conn.Close()
+	conn.legacy = true
+	if err = sendServiceInfo(conn, info); err != nil {
+		return
+	}
+	return receiveRemoteServiceInfo(conn)
+}
+
+//flushMainRoutine wait until messages posted to main routine before processed
+func flushMainRoutine(t *testing.T, peer *memoryPeer) {
+	//rejection posted after reply written
+	time.Sleep(100 * time.Millisecond)
+	msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+	if err := peer.SendToSelf(msg); err != nil {
+		t.Fatal(err)
+	}
+	receiveEvent(t, peer.MessageChan, ComputePoolReadyEvent)
+}
+
+func Test_HandshakeRejectedByAcceptor(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core, cell = startMemoryPair(t, network)
+	var impostor = handshakeInfo{Name: cell.GetName(), Type: ServiceTypeCell, ProtocolVersion: LegacyProtocolVersion,
+		Codecs: defaultWireCodecs(), Compressions: defaultCompressions()}
+	var cases = []struct {
+		Reason       string
+		MinVersion   uint
+		Capabilities []string
+		Impostor     handshakeInfo
+	}{
+		{"version", ProtocolVersion, nil, impostor},
+		{"capability", LegacyProtocolVersion, []string{"audit"}, impostor},
+	}
+	cases[1].Impostor.ProtocolVersion = ProtocolVersion
+	for _, current := range cases {
+		if err := core.SetPeerRequirement(current.MinVersion, current.Capabilities); err != nil {
+			t.Fatal(err)
+		}
+		_, err := dialHandshake(network, core, current.Impostor)
+		if _, isRejected := err.(*RejectedError); !isRejected {
+			t.Fatalf("impostor not rejected by %s: %v", current.Reason, err)
+		}
+		flushMainRoutine(t, core)
+		//live connection with the same name untouched
+		select {
+		case <-core.EventChan:
+			t.Fatalf("OnServiceDisconnected invoked by %s rejection", current.Reason)
+		default:
+		}
+		if err = core.GetDisconnectReason(cell.GetName()); err != nil {
+			t.Fatalf("disconnect reason overwritten by %s rejection: %s", current.Reason, err.Error())
+		}
+		if _, err = core.GetPeerInfo(cell.GetName()); err != nil {
+			t.Fatalf("peer info lost after %s rejection: %s", current.Reason, err.Error())
+		}
+	}
+}
+
+func Test_HandshakeRejectedByDialer(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	var dialer = createMemoryPeer(t, network, ServiceTypeCore, 1)
+	if err := dialer.Start(); err != nil {
+		t.Fatalf("start dialer fail: %s", err.Error())
+	}
+	defer dialer.Stop()
+	var cases = []struct {
+		Reason  string
+		Prepare func()
+	}{
+		{"capability required by dialer", func() {
+			dialer.SetPeerRequirement(LegacyProtocolVersion, []string{"audit"})
+		}},
+		{"capability required by acceptor", func() {
+			dialer.SetPeerRequirement(LegacyProtocolVersion, nil)
+			core.SetPeerRequirement(ProtocolVersion, []string{"audit"})
+		}},
+	}
+	for _, current := range cases {
+		current.Prepare()
+		if err := dialer.connectRemoteService(core.GetListenAddress(), core.GetListenPort()); err == nil {
+			t.Fatalf("connected without %s", current.Reason)
+		}
+		waitMemoryEvent(t, dialer.EventChan, "rejected")
+		if err := dialer.GetDisconnectReason(core.GetName()); err == nil {
+			t.Fatalf("no disconnect reason for %s", current.Reason)
+		} else {
+			t.Logf("%s: %s", current.Reason, err.Error())
+		}
+		if _, err := dialer.GetPeerInfo(core.GetName()); err == nil {
+			t.Fatalf("peer info available when rejected for %s", current.Reason)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package framework
+
+import (
+	"log"
+	"sync"
+)
+
+type InterceptDirection int
+
+const (
+	InterceptInbound = InterceptDirection(iota)
+	InterceptOutbound
+)
+
+//InterceptAction returned by interceptor when message accepted
+type InterceptAction int
+
+const (
+	//InterceptContinue pass message to next interceptor, then handler or outgoing queue
+	InterceptContinue = InterceptAction(iota)
+	//InterceptHandled stop the chain, message consumed by interceptor and never delivered
+	InterceptHandled
+)
+
+//InterceptContext describe the peer of an intercepted message
+type InterceptContext struct {
+	Direction InterceptDirection
+	Peer      PeerInfo //only Name available when Local
+	Local     bool     //message from endpoint itself or submodule
+}
+
+//Interceptor inspect or modify message in place, return error to reject it,
+//rejected outbound message returns error to sender, rejected inbound message dropped
+type Interceptor func(ctx InterceptContext, msg Message) (InterceptAction, error)
+
+//system messages never intercepted, connection level messages included
+var systemMessages = map[MessageID]bool{
+	ConnectionOpenedEvent:     true,
+	ConnectionClosedEvent:     true,
+	ConnectionKeepAliveEvent:  true,
+	ServiceAvailableEvent:     true,
+	ServiceReadyEvent:         true,
+	ServiceConnectedEvent:     true,
+	ServiceDisconnectedEvent:  true,
+	ServiceReconnectEvent:     true,
+	ServiceElectEvent:         true,
+	ServiceLeaderChangedEvent: true,
+	ServiceSubscribeEvent:     true,
+	ServiceUnsubscribeEvent:   true,
+}
+
+func isSystemMessage(id MessageID) bool {
+	return systemMessages[id]
+}
+
+type interceptorChain struct {
+	lock     sync.RWMutex
+	inbound  []Interceptor
+	outbound []Interceptor
+}
+
+func newInterceptorChain() *interceptorChain {
+	return &interceptorChain{}
+}
+
+func (chain *interceptorChain) add(direction InterceptDirection, interceptor Interceptor) {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+	if InterceptInbound == direction {
+		chain.inbound = append(chain.inbound, interceptor)
+	} else {
+		chain.outbound = append(chain.outbound, interceptor)
+	}
+}
+
+func (chain *interceptorChain) list(direction InterceptDirection) []Interceptor {
+	chain.lock.RLock()
+	defer chain.lock.RUnlock()
+	if InterceptInbound == direction {
+		return chain.inbound
+	}
+	return chain.outbound
+}
+
+//invoke interceptors in order of registration, return false when message should not deliver
+func (chain *interceptorChain) invoke(ctx InterceptContext, msg Message) (deliver bool, err error) {
+	for _, interceptor := range chain.list(ctx.Direction) {
+		action, err := interceptor(ctx, msg)
+		if err != nil {
+			return false, err
+		}
+		if InterceptHandled == action {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+//AddInboundInterceptor invoked in order of registration before message delivered to request, subscriber or handler
+func (endpoint *EndpointService) AddInboundInterceptor(interceptor Interceptor) {
+	endpoint.interceptors.add(InterceptInbound, interceptor)
+}
+
+//AddOutboundInterceptor invoked in order of registration before message put into outgoing queue of remote service
+func (endpoint *EndpointService) AddOutboundInterceptor(interceptor Interceptor) {
+	endpoint.interceptors.add(InterceptOutbound, interceptor)
+}
+
+//interceptInbound return false when message rejected or consumed
+func (endpoint *EndpointService) interceptInbound(incoming incomingMessage) bool {
+	var msg = incoming.Message
+	var sender = msg.GetSender()
+	var ctx = InterceptContext{Direction: InterceptInbound, Local: !incoming.Remote}
+	if !incoming.Remote {
+		ctx.Peer = PeerInfo{Name: sender}
+	} else if entry, exists := endpoint.connections.get(incoming.Name); exists && incoming.Type == entry.Type {
+		ctx.Peer = entry.Peer
+	} else {
+		//disconnected before message handled
+		ctx.Peer = PeerInfo{Name: incoming.Name, Type: incoming.Type}
+	}
+	deliver, err := endpoint.interceptors.invoke(ctx, msg)
+	if err != nil {
+		log.Printf("<endpoint> message %08X from '%s' rejected: %s", uint32(msg.GetID()), sender, err.Error())
+	}
+	return deliver
+}
+
+//interceptOutbound return false with nil error when message consumed
+func (endpoint *EndpointService) interceptOutbound(entry connEntry, msg Message) (bool, error) {
+	if isSystemMessage(msg.GetID()) {
+		return true, nil
+	}
+	return endpoint.interceptors.invoke(InterceptContext{Direction: InterceptOutbound, Peer: entry.Peer}, msg)
+}
@@ -0,0 +1,169 @@
+package framework
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Interceptor(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core, cell = startMemoryPair(t, network)
+	core.AddOutboundInterceptor(func(ctx InterceptContext, msg Message) (InterceptAction, error) {
+		if ctx.Peer.Name != cell.GetName() || InterceptOutbound != ctx.Direction {
+			return InterceptContinue, errors.New("unexpected context")
+		}
+		switch msg.GetID() {
+		case AddressPoolChangedEvent:
+			return InterceptContinue, errors.New("rejected")
+		case ComputePoolReadyEvent:
+			return InterceptHandled, nil
+		}
+		msg.SetString(ParamKeyName, "stamped")
+		return InterceptContinue, nil
+	})
+	var trace = make(chan string, 4)
+	cell.AddInboundInterceptor(func(ctx InterceptContext, msg Message) (InterceptAction, error) {
+		if ctx.Local || ServiceTypeCore != ctx.Peer.Type || ctx.Peer.Name != core.GetName() {
+			return InterceptContinue, errors.New("unexpected context")
+		}
+		trace <- "first"
+		if GuestStoppedEvent == msg.GetID() {
+			return InterceptContinue, errors.New("rejected")
+		}
+		return InterceptContinue, nil
+	})
+	cell.AddInboundInterceptor(func(ctx InterceptContext, msg Message) (InterceptAction, error) {
+		trace <- "second"
+		return InterceptContinue, nil
+	})
+	var send = func(id MessageID) error {
+		msg, _ := CreateJsonMessage(id)
+		return core.SendMessage(msg, cell.GetName())
+	}
+	if err := send(AddressPoolChangedEvent); err == nil {
+		t.Fatal("rejected message sent")
+	}
+	if err := send(ComputePoolReadyEvent); err != nil {
+		t.Fatalf("send handled message fail: %s", err.Error())
+	}
+	if err := send(GuestStoppedEvent); err != nil {
+		t.Fatalf("send message fail: %s", err.Error())
+	}
+	if err := send(GuestStartedEvent); err != nil {
+		t.Fatalf("send message fail: %s", err.Error())
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait message timeout")
+	case msg := <-cell.MessageChan:
+		if GuestStartedEvent != msg.GetID() {
+			t.Fatalf("unexpected message %08X received", uint32(msg.GetID()))
+		}
+		if name, _ := msg.GetString(ParamKeyName); "stamped" != name {
+			t.Fatal("message not stamped")
+		}
+	}
+	for _, expected := range []string{"first", "first", "second"} {
+		if current := <-trace; expected != current {
+			t.Fatalf("expect %s interceptor, but %s invoked", expected, current)
+		}
+	}
+	select {
+	case msg := <-cell.MessageChan:
+		t.Fatalf("unexpected message %08X received", uint32(msg.GetID()))
+	default:
+	}
+}
+
+func Test_InterceptAfterDisconnected(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core, cell = startMemoryPair(t, network)
+	var contexts = make(chan InterceptContext, 2)
+	core.AddInboundInterceptor(func(ctx InterceptContext, msg Message) (InterceptAction, error) {
+		if GuestStartedEvent != msg.GetID() {
+			contexts <- ctx
+		}
+		return InterceptContinue, nil
+	})
+	var receive = func() InterceptContext {
+		select {
+		case <-time.After(5 * time.Second):
+			t.Fatal("wait interceptor timeout")
+		case ctx := <-contexts:
+			return ctx
+		}
+		return InterceptContext{}
+	}
+	//block main routine of core
+	for i := 0; i <= cap(core.MessageChan); i++ {
+		msg, _ := CreateJsonMessage(GuestStartedEvent)
+		if err := cell.SendMessage(msg, core.GetName()); err != nil {
+			t.Fatalf("send message fail: %s", err.Error())
+		}
+	}
+	remote, _ := CreateJsonMessage(InstanceMigratedEvent)
+	if err := cell.SendMessage(remote, core.GetName()); err != nil {
+		t.Fatalf("send message fail: %s", err.Error())
+	}
+	waitCondition(t, func() bool {
+		return 1 == len(core.incomingMessageChan)
+	}, "message queued")
+	if err := cell.Stop(); err != nil {
+		t.Fatalf("stop cell fail: %s", err.Error())
+	}
+	waitCondition(t, func() bool {
+		_, exists := core.connections.get(cell.GetName())
+		return !exists
+	}, "connection removed")
+	for i := 0; i <= cap(core.MessageChan); i++ {
+		receiveEvent(t, core.MessageChan, GuestStartedEvent)
+	}
+	//origin recorded when arrived
+	if ctx := receive(); ctx.Local || cell.GetName() != ctx.Peer.Name || ServiceTypeCell != ctx.Peer.Type {
+		t.Fatalf("remote message intercepted with context %+v", ctx)
+	}
+	receiveEvent(t, core.MessageChan, InstanceMigratedEvent)
+	local, _ := CreateJsonMessage(InstanceMigratedEvent)
+	if err := core.SendToSelf(local); err != nil {
+		t.Fatal(err)
+	}
+	if ctx := receive(); !ctx.Local || core.GetName() != ctx.Peer.Name {
+		t.Fatalf("local message intercepted with context %+v", ctx)
+	}
+}
+
+func Test_InterceptKeepAlive(t *testing.T) {
+	var options = EndpointOptions{
+		KeepAliveInterval:   100 * time.Millisecond,
+		LostThreshold:       300 * time.Millisecond,
+		DisconnectThreshold: 500 * time.Millisecond,
+		CheckInterval:       100 * time.Millisecond,
+	}
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0, options)
+	var cell = createMemoryPeer(t, network, ServiceTypeCell, 1, options)
+	for _, peer := range []*memoryPeer{core, cell} {
+		peer.AddOutboundInterceptor(func(ctx InterceptContext, msg Message) (InterceptAction, error) {
+			return InterceptContinue, errors.New("unauthorized")
+		})
+		if err := peer.Start(); err != nil {
+			t.Fatalf("start %s fail: %s", peer.GetName(), err.Error())
+		}
+		defer peer.Stop()
+	}
+	waitMemoryEvent(t, cell.EventChan, "cell connect")
+	waitMemoryEvent(t, core.EventChan, "cell accepted")
+	//heartbeat never rejected by interceptor
+	time.Sleep(4 * options.DisconnectThreshold)
+	for _, peer := range []*memoryPeer{core, cell} {
+		select {
+		case <-peer.EventChan:
+			t.Fatalf("%s disconnected with interceptor", peer.GetName())
+		default:
+		}
+	}
+	if _, err := cell.GetPeerInfo(core.GetName()); err != nil {
+		t.Fatalf("get peer info fail: %s", err.Error())
+	}
+}
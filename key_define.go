@@ -477,6 +477,11 @@ const (
 	EventEnable
 	EventDisable
 	EventReset
+	EventAuthenticate
+	EventReconnect
+	EventElect
+	EventSubscribe
+	EventUnsubscribe
 )
 
 const (
@@ -484,10 +489,16 @@ const (
 	ServiceReadyEvent     = EventReady<<OperateOffset | ResourceService<<ResourceOffset | MessageEvent
 	ServiceConnectedEvent = EventConnect<<OperateOffset | ResourceService<<ResourceOffset | MessageEvent
 	ServiceDisconnectedEvent = EventDisconnect<<OperateOffset | ResourceService<<ResourceOffset | MessageEvent
+	ServiceReconnectEvent = EventReconnect<<OperateOffset | ResourceService<<ResourceOffset | MessageEvent
+	ServiceElectEvent = EventElect<<OperateOffset | ResourceService<<ResourceOffset | MessageEvent
+	ServiceLeaderChangedEvent = EventChange<<OperateOffset | ResourceService<<ResourceOffset | MessageEvent
+	ServiceSubscribeEvent = EventSubscribe<<OperateOffset | ResourceService<<ResourceOffset | MessageEvent
+	ServiceUnsubscribeEvent = EventUnsubscribe<<OperateOffset | ResourceService<<ResourceOffset | MessageEvent
 
 	ConnectionOpenedEvent    = EventOpen<<OperateOffset | ResourceConnection<<ResourceOffset | MessageEvent
 	ConnectionClosedEvent    = EventClose<<OperateOffset | ResourceConnection<<ResourceOffset | MessageEvent
 	ConnectionKeepAliveEvent = EventHeartBeat<<OperateOffset | ResourceConnection<<ResourceOffset | MessageEvent
+	ConnectionAuthenticateEvent = EventAuthenticate<<OperateOffset | ResourceConnection<<ResourceOffset | MessageEvent
 
 	CellStatusReportEvent = EventReport<<OperateOffset | ResourceComputeCell<<ResourceOffset | MessageEvent
 
@@ -615,4 +626,14 @@ const (
 	ParamKeyIndex
 	ParamKeySecurity
 	ParamKeyPolicy
+	ParamKeyCodec
+	ParamKeyCompress
+	ParamKeyCapability
+	ParamKeyNonce
+	ParamKeyProof
+	ParamKeyDelay
+	ParamKeySequence
+	ParamKeyTimestamp
+	ParamKeyTopic
+	ParamKeyPublish
 )
\ No newline at end of file
@@ -0,0 +1,158 @@
+package framework
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//LatencyStatistic measured by keep alive echoed from remote service
+type LatencyStatistic struct {
+	Last     time.Duration
+	Smoothed time.Duration
+	Jitter   time.Duration //smoothed deviation of round trip time
+	Probes   uint64        //keep alive finished, echoed or lost
+	Lost     uint64
+	Echoed   uint64
+}
+
+//Loss ratio of keep alive, in [0, 1]
+func (statistic LatencyStatistic) Loss() float64 {
+	if 0 == statistic.Probes {
+		return 0
+	}
+	return float64(statistic.Lost) / float64(statistic.Probes)
+}
+
+//Measured return true when any echo received
+func (statistic LatencyStatistic) Measured() bool {
+	return 0 != statistic.Echoed
+}
+
+//latencyMeter of a connection, smoothing same as TCP retransmission timer (RFC 6298)
+type latencyMeter struct {
+	lock      sync.Mutex
+	sequence  uint64
+	echoed    bool //echo of current sequence received
+	statistic LatencyStatistic
+}
+
+const (
+	latencySmoothShift = 3 //gain 1/8
+	latencyJitterShift = 2 //gain 1/4
+)
+
+func newLatencyMeter() *latencyMeter {
+	return &latencyMeter{echoed: true}
+}
+
+//probe start next sequence, previous one lost if not echoed
+func (meter *latencyMeter) probe() uint64 {
+	meter.lock.Lock()
+	defer meter.lock.Unlock()
+	if 0 != meter.sequence {
+		meter.statistic.Probes++
+		if !meter.echoed {
+			meter.statistic.Lost++
+		}
+	}
+	meter.sequence++
+	meter.echoed = false
+	return meter.sequence
+}
+
+//echo ignored when late or duplicated
+func (meter *latencyMeter) echo(sequence uint64, rtt time.Duration) bool {
+	meter.lock.Lock()
+	defer meter.lock.Unlock()
+	if sequence != meter.sequence || meter.echoed {
+		return false
+	}
+	meter.echoed = true
+	var statistic = &meter.statistic
+	statistic.Echoed++
+	statistic.Last = rtt
+	if 1 == statistic.Echoed {
+		statistic.Smoothed = rtt
+		statistic.Jitter = rtt / 2
+		return true
+	}
+	var deviation = statistic.Smoothed - rtt
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	statistic.Jitter += (deviation - statistic.Jitter) >> latencyJitterShift
+	statistic.Smoothed += (rtt - statistic.Smoothed) >> latencySmoothShift
+	return true
+}
+
+func (meter *latencyMeter) get() LatencyStatistic {
+	meter.lock.Lock()
+	defer meter.lock.Unlock()
+	return meter.statistic
+}
+
+//createKeepAlive carry sequence and timestamp when remote service echo keep alive
+func createKeepAlive(entry connEntry) (msg Message, err error) {
+	if msg, err = CreateJsonMessage(ConnectionKeepAliveEvent); err != nil {
+		return
+	}
+	if entry.Peer.HasCapability(CapabilityEcho) {
+		msg.SetUInt(ParamKeySequence, uint(entry.Latency.probe()))
+		msg.SetUInt(ParamKeyTimestamp, uint(time.Now().UnixNano()))
+	}
+	return msg, nil
+}
+
+//echoKeepAlive return the echo of keep alive with sequence
+func echoKeepAlive(keepAlive Message) (echo Message, required bool) {
+	if isEcho, _ := keepAlive.GetBoolean(ParamKeyFlag); isEcho {
+		return nil, false
+	}
+	sequence, err := keepAlive.GetUInt(ParamKeySequence)
+	if err != nil {
+		return nil, false
+	}
+	timestamp, err := keepAlive.GetUInt(ParamKeyTimestamp)
+	if err != nil {
+		return nil, false
+	}
+	if echo, err = CreateJsonMessage(ConnectionKeepAliveEvent); err != nil {
+		return nil, false
+	}
+	echo.SetBoolean(ParamKeyFlag, true)
+	echo.SetUInt(ParamKeySequence, sequence)
+	echo.SetUInt(ParamKeyTimestamp, timestamp)
+	return echo, true
+}
+
+//parseEcho return sequence and round trip time of an echoed keep alive
+func parseEcho(msg Message, received time.Time) (sequence uint64, rtt time.Duration, isEcho bool) {
+	if flag, _ := msg.GetBoolean(ParamKeyFlag); !flag {
+		return 0, 0, false
+	}
+	value, err := msg.GetUInt(ParamKeySequence)
+	if err != nil {
+		return 0, 0, false
+	}
+	timestamp, err := msg.GetUInt(ParamKeyTimestamp)
+	if err != nil {
+		return 0, 0, false
+	}
+	rtt = received.Sub(time.Unix(0, int64(timestamp)))
+	if rtt < 0 {
+		return 0, 0, false
+	}
+	return uint64(value), rtt, true
+}
+
+//GetLatency return round trip time and loss of keep alive to a connected service,
+//not measured when remote endpoint does not echo
+func (endpoint *EndpointService) GetLatency(name string) (statistic LatencyStatistic, err error) {
+	entry, exists := endpoint.connections.get(name)
+	if !exists {
+		err = fmt.Errorf("invalid service '%s'", name)
+		return
+	}
+	return entry.Latency.get(), nil
+}
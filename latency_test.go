@@ -0,0 +1,115 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_LatencyMeter(t *testing.T) {
+	var meter = newLatencyMeter()
+	var sequence = meter.probe()
+	if !meter.echo(sequence, 80*time.Millisecond) {
+		t.Fatal("echo rejected")
+	}
+	if meter.echo(sequence, 80*time.Millisecond) {
+		t.Fatal("duplicated echo accepted")
+	}
+	var statistic = meter.get()
+	if 80*time.Millisecond != statistic.Smoothed || 40*time.Millisecond != statistic.Jitter {
+		t.Fatalf("unexpected first measurement %+v", statistic)
+	}
+	sequence = meter.probe()
+	if !meter.echo(sequence, 160*time.Millisecond) {
+		t.Fatal("echo rejected")
+	}
+	statistic = meter.get()
+	if 160*time.Millisecond != statistic.Last || 90*time.Millisecond != statistic.Smoothed ||
+		50*time.Millisecond != statistic.Jitter {
+		t.Fatalf("unexpected smoothed measurement %+v", statistic)
+	}
+	//lost and late
+	var lost = meter.probe()
+	meter.probe()
+	if meter.echo(lost, time.Second) {
+		t.Fatal("late echo accepted")
+	}
+	statistic = meter.get()
+	if 3 != statistic.Probes || 1 != statistic.Lost {
+		t.Fatalf("unexpected loss %d/%d", statistic.Lost, statistic.Probes)
+	}
+}
+
+func Test_KeepAliveEcho(t *testing.T) {
+	var entry = connEntry{Peer: PeerInfo{Capabilities: defaultCapabilities()}, Latency: newLatencyMeter()}
+	keepAlive, err := createKeepAlive(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo, required := echoKeepAlive(keepAlive)
+	if !required {
+		t.Fatal("echo not required")
+	}
+	if _, required = echoKeepAlive(echo); required {
+		t.Fatal("echo of echo required")
+	}
+	sequence, rtt, isEcho := parseEcho(echo, time.Now().Add(time.Millisecond))
+	if !isEcho || 1 != sequence || rtt < time.Millisecond {
+		t.Fatalf("unexpected echo %d/%s", sequence, rtt)
+	}
+	//legacy endpoint
+	entry.Peer.Capabilities = []string{CapabilityFraming}
+	if keepAlive, err = createKeepAlive(entry); err != nil {
+		t.Fatal(err)
+	}
+	if _, required = echoKeepAlive(keepAlive); required {
+		t.Fatal("echo required by legacy keep alive")
+	}
+}
+
+func Test_ConnectionLatency(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var options = EndpointOptions{KeepAliveInterval: 50 * time.Millisecond}
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0, options)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	var peer = createMemoryPeer(t, network, ServiceTypeCell, 1, options)
+	if err := peer.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	defer peer.Stop()
+	waitMemoryEvent(t, peer.EventChan, "peer connect")
+	waitCondition(t, func() bool {
+		statistic, err := core.GetLatency(peer.GetName())
+		return err == nil && statistic.Measured()
+	}, "latency of peer")
+	waitCondition(t, func() bool {
+		snapshot, err := peer.GetConnection(core.GetName())
+		return err == nil && snapshot.Latency.Measured()
+	}, "latency of stub")
+	var stub = peer.stubs.all()[0]
+	waitCondition(t, func() bool {
+		peer.stubs.lock.RLock()
+		defer peer.stubs.lock.RUnlock()
+		_, measured := peer.stubs.latency[stubAddress(stub)]
+		return measured
+	}, "latency of stub recorded")
+}
+
+func Test_StubLatencyPreferred(t *testing.T) {
+	var list = newStubList()
+	var stubs = []ServiceLocation{
+		{ServiceTypeStringCore, "", "192.168.1.1", 5600, 0},
+		{ServiceTypeStringCore, "", "192.168.1.2", 5600, 0},
+		{ServiceTypeStringCore, "", "192.168.1.3", 5600, 1},
+	}
+	list.latency[stubAddress(stubs[1])] = 10 * time.Millisecond
+	list.latency[stubAddress(stubs[2])] = time.Millisecond
+	var ranked = list.update(stubs)
+	for index, address := range []string{"192.168.1.2", "192.168.1.1", "192.168.1.3"} {
+		if ranked[index].Address != address {
+			t.Fatalf("unexpected stub %s at %d", ranked[index].Address, index)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+//MemoryNetwork connect endpoints in the same process without multicast or socket,
+//for test only
+type MemoryNetwork struct {
+	lock      *sync.Mutex
+	listeners map[string]*memoryListener
+	services  []ServiceLocation
+	dialCount int
+}
+
+const (
+	TransportProtocolMemory = "memory"
+	//MemoryNetworkHost is the only host in memory network
+	MemoryNetworkHost = "memory"
+)
+
+func CreateMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{lock: &sync.Mutex{}, listeners: map[string]*memoryListener{}}
+}
+
+//WithMemoryNetwork replace transport and stub discovery of endpoint with network
+func WithMemoryNetwork(network *MemoryNetwork) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		endpoint.transport = &memoryTransport{network}
+		endpoint.publisher = &memoryPublisher{network: network}
+		endpoint.locator = &memoryLocator{network}
+		return nil
+	})
+}
+
+type memoryAddr string
+
+func (addr memoryAddr) Network() string {
+	return TransportProtocolMemory
+}
+
+func (addr memoryAddr) String() string {
+	return string(addr)
+}
+
+type memoryTransport struct {
+	network *MemoryNetwork
+}
+
+func (transport *memoryTransport) Protocol() string {
+	return TransportProtocolMemory
+}
+
+func (transport *memoryTransport) Listen(address string) (TransportListener, error) {
+	var network = transport.network
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	if _, exists := network.listeners[address]; exists {
+		return nil, fmt.Errorf("address %s already in use", address)
+	}
+	var listener = &memoryListener{network: network, address: memoryAddr(address),
+		incoming: make(chan TransportConn, DefaultMessageQueueSize), closed: make(chan bool)}
+	network.listeners[address] = listener
+	return listener, nil
+}
+
+func (transport *memoryTransport) Dial(address string) (TransportConn, error) {
+	var network = transport.network
+	network.lock.Lock()
+	listener, exists := network.listeners[address]
+	network.dialCount++
+	var localAddress = memoryAddr(fmt.Sprintf("%s:%d", MemoryNetworkHost, ListenPortRangeEnd+network.dialCount))
+	network.lock.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("connection to %s refused", address)
+	}
+	client, server := createMemoryConnPair(localAddress, listener.address)
+	select {
+	case listener.incoming <- server:
+		return client, nil
+	case <-listener.closed:
+		return nil, fmt.Errorf("connection to %s refused", address)
+	}
+}
+
+type memoryListener struct {
+	network   *MemoryNetwork
+	address   memoryAddr
+	incoming  chan TransportConn
+	closed    chan bool
+	closeOnce sync.Once
+}
+
+func (listener *memoryListener) Accept() (TransportConn, error) {
+	select {
+	case conn := <-listener.incoming:
+		return conn, nil
+	case <-listener.closed:
+		return nil, errors.New("listener closed")
+	}
+}
+
+func (listener *memoryListener) Close() error {
+	listener.closeOnce.Do(func() {
+		var network = listener.network
+		network.lock.Lock()
+		delete(network.listeners, listener.address.String())
+		network.lock.Unlock()
+		close(listener.closed)
+	})
+	return nil
+}
+
+func (listener *memoryListener) Addr() net.Addr {
+	return listener.address
+}
+
+//memoryPipe is one direction of connection, writer never blocked
+type memoryPipe struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	data     []byte
+	closed   bool
+	deadline time.Time
+	timer    *time.Timer
+}
+
+func newMemoryPipe() *memoryPipe {
+	var pipe = &memoryPipe{}
+	pipe.cond = sync.NewCond(&pipe.lock)
+	return pipe
+}
+
+func (pipe *memoryPipe) read(buf []byte) (int, error) {
+	pipe.lock.Lock()
+	defer pipe.lock.Unlock()
+	for 0 == len(pipe.data) {
+		if pipe.closed {
+			return 0, io.EOF
+		}
+		if !pipe.deadline.IsZero() && !time.Now().Before(pipe.deadline) {
+			return 0, errors.New("read timeout")
+		}
+		pipe.cond.Wait()
+	}
+	var count = copy(buf, pipe.data)
+	pipe.data = pipe.data[count:]
+	return count, nil
+}
+
+func (pipe *memoryPipe) write(data []byte) (int, error) {
+	pipe.lock.Lock()
+	defer pipe.lock.Unlock()
+	if pipe.closed {
+		return 0, io.ErrClosedPipe
+	}
+	pipe.data = append(pipe.data, data...)
+	pipe.cond.Broadcast()
+	return len(data), nil
+}
+
+func (pipe *memoryPipe) setDeadline(deadline time.Time) {
+	pipe.lock.Lock()
+	defer pipe.lock.Unlock()
+	pipe.deadline = deadline
+	if nil != pipe.timer {
+		pipe.timer.Stop()
+		pipe.timer = nil
+	}
+	if !deadline.IsZero() {
+		//wake up reader when expired
+		pipe.timer = time.AfterFunc(time.Until(deadline), func() {
+			pipe.lock.Lock()
+			pipe.cond.Broadcast()
+			pipe.lock.Unlock()
+		})
+	}
+	pipe.cond.Broadcast()
+}
+
+func (pipe *memoryPipe) close() {
+	pipe.lock.Lock()
+	defer pipe.lock.Unlock()
+	pipe.closed = true
+	pipe.cond.Broadcast()
+}
+
+type memoryConn struct {
+	incoming *memoryPipe
+	outgoing *memoryPipe
+	remote   memoryAddr
+}
+
+func createMemoryConnPair(clientAddress, serverAddress memoryAddr) (client, server *memoryConn) {
+	var upstream, downstream = newMemoryPipe(), newMemoryPipe()
+	client = &memoryConn{incoming: downstream, outgoing: upstream, remote: serverAddress}
+	server = &memoryConn{incoming: upstream, outgoing: downstream, remote: clientAddress}
+	return client, server
+}
+
+func (conn *memoryConn) Read(buf []byte) (int, error) {
+	return conn.incoming.read(buf)
+}
+
+func (conn *memoryConn) Write(data []byte) (int, error) {
+	return conn.outgoing.write(data)
+}
+
+func (conn *memoryConn) Close() error {
+	conn.incoming.close()
+	conn.outgoing.close()
+	return nil
+}
+
+func (conn *memoryConn) RemoteAddr() net.Addr {
+	return conn.remote
+}
+
+func (conn *memoryConn) SetReadDeadline(t time.Time) error {
+	conn.incoming.setDeadline(t)
+	return nil
+}
+
+type memoryPublisher struct {
+	network  *MemoryNetwork
+	services []ServiceLocation
+}
+
+func (publisher *memoryPublisher) Publish(service ServiceLocation) error {
+	publisher.services = append(publisher.services, service)
+	return nil
+}
+
+func (publisher *memoryPublisher) Start() error {
+	var network = publisher.network
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	network.services = append(network.services, publisher.services...)
+	return nil
+}
+
+func (publisher *memoryPublisher) Stop() error {
+	var network = publisher.network
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	var remains []ServiceLocation
+	for _, service := range network.services {
+		if !containsLocation(publisher.services, service) {
+			remains = append(remains, service)
+		}
+	}
+	network.services = remains
+	return nil
+}
+
+type memoryLocator struct {
+	network *MemoryNetwork
+}
+
+func (locator *memoryLocator) Locate(timeout time.Duration) (localAddress string, services []ServiceLocation, err error) {
+	var network = locator.network
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	if 0 == len(network.services) {
+		err = errors.New("no service published")
+		return
+	}
+	services = make([]ServiceLocation, len(network.services))
+	copy(services, network.services)
+	return MemoryNetworkHost, services, nil
+}
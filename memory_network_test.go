@@ -0,0 +1,198 @@
+package framework
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+type memoryPeer struct {
+	EndpointService //base class
+	EventChan       chan bool
+	MessageChan     chan Message
+}
+
+func (peer *memoryPeer) OnMessageReceived(msg Message) {
+	peer.MessageChan <- msg
+}
+
+func (peer *memoryPeer) OnServiceConnected(name string, t ServiceType, remote string) {
+	peer.EventChan <- true
+}
+
+func (peer *memoryPeer) OnServiceDisconnected(name string, t ServiceType, gracefully bool) {
+	peer.EventChan <- true
+}
+
+func (peer *memoryPeer) OnDependencyReady() {
+}
+
+func (peer *memoryPeer) InitialEndpoint() error {
+	return nil
+}
+
+func (peer *memoryPeer) OnEndpointStarted() error {
+	return nil
+}
+
+func (peer *memoryPeer) OnEndpointStopped() {
+}
+
+func createMemoryPeer(t *testing.T, network *MemoryNetwork, serviceType ServiceType, index byte, options ...EndpointOption) *memoryPeer {
+	var endpoint EndpointService
+	var err error
+	options = append([]EndpointOption{WithMemoryNetwork(network)}, options...)
+	if ServiceTypeCore == serviceType {
+		endpoint, err = CreateStubEndpoint("", 0, "test", MemoryNetworkHost, options...)
+	} else {
+		endpoint, err = CreatePeerEndpoint("", 0, "test", options...)
+	}
+	if err != nil {
+		t.Fatalf("create endpoint fail: %s", err.Error())
+	}
+	var peer = &memoryPeer{endpoint, make(chan bool, 8), make(chan Message, 8)}
+	peer.handler = peer
+	var inf = net.Interface{HardwareAddr: net.HardwareAddr{0, 0, 0, 0, 0, index}}
+	if err = peer.GenerateName(serviceType, &inf); err != nil {
+		t.Fatal(err)
+	}
+	return peer
+}
+
+func waitMemoryEvent(t *testing.T, events chan bool, event string) {
+	const waitTimeout = 5 * time.Second
+	select {
+	case <-time.After(waitTimeout):
+		t.Fatalf("wait %s timeout", event)
+	case <-events:
+	}
+}
+
+func Test_MemoryNetwork(t *testing.T) {
+	const (
+		peerCount = 2
+	)
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	var peers []*memoryPeer
+	for i := 0; i < peerCount; i++ {
+		var peer = createMemoryPeer(t, network, ServiceTypeCell, byte(i+1))
+		if err := peer.Start(); err != nil {
+			t.Fatalf("start peer fail: %s", err.Error())
+		}
+		waitMemoryEvent(t, peer.EventChan, fmt.Sprintf("%s connect", peer.GetName()))
+		waitMemoryEvent(t, core.EventChan, fmt.Sprintf("%s accepted", peer.GetName()))
+		peers = append(peers, peer)
+	}
+	for _, peer := range peers {
+		msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+		msg.SetString(ParamKeyName, peer.GetName())
+		if err := core.SendMessage(msg, peer.GetName()); err != nil {
+			t.Fatalf("send message fail: %s", err.Error())
+		}
+		select {
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s wait message timeout", peer.GetName())
+		case received := <-peer.MessageChan:
+			if name, _ := received.GetString(ParamKeyName); name != peer.GetName() {
+				t.Fatalf("unexpected message for '%s' received by %s", name, peer.GetName())
+			}
+			if received.GetSender() != core.GetName() {
+				t.Fatalf("unexpected sender '%s'", received.GetSender())
+			}
+		}
+	}
+	if err := core.Stop(); err != nil {
+		t.Fatalf("stop core fail: %s", err.Error())
+	}
+	for _, peer := range peers {
+		waitMemoryEvent(t, peer.EventChan, fmt.Sprintf("%s disconnect", peer.GetName()))
+		if err := peer.Stop(); err != nil {
+			t.Fatalf("stop peer fail: %s", err.Error())
+		}
+	}
+}
+
+func createTCPPeer(t *testing.T, serviceType ServiceType, index byte, options ...EndpointOption) *memoryPeer {
+	var endpoint EndpointService
+	var err error
+	options = append([]EndpointOption{WithTransport(&TCPTransport{})}, options...)
+	if ServiceTypeCore == serviceType {
+		endpoint, err = CreateStubEndpoint("", 0, "test", "127.0.0.1", options...)
+	} else {
+		endpoint, err = CreatePeerEndpoint("", 0, "test", options...)
+	}
+	if err != nil {
+		t.Fatalf("create endpoint fail: %s", err.Error())
+	}
+	var peer = &memoryPeer{endpoint, make(chan bool, 8), make(chan Message, 8)}
+	peer.handler = peer
+	var inf = net.Interface{HardwareAddr: net.HardwareAddr{0, 0, 0, 0, 0, index}}
+	if err = peer.GenerateName(serviceType, &inf); err != nil {
+		t.Fatal(err)
+	}
+	return peer
+}
+
+func Test_TCPEndpoint(t *testing.T) {
+	var core = createTCPPeer(t, ServiceTypeCore, 0, WithStubPublisher(&memoryPublisher{network: CreateMemoryNetwork()}))
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	var stub = fmt.Sprintf("%s://127.0.0.1:%d", TransportProtocolTCP, core.GetListenPort())
+	var peer = createTCPPeer(t, ServiceTypeCell, 1, WithStaticStubs(stub))
+	if err := peer.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	defer peer.Stop()
+	waitMemoryEvent(t, peer.EventChan, "peer connect")
+	waitMemoryEvent(t, core.EventChan, "peer accepted")
+	var exchange = func(sender, receiver *memoryPeer) {
+		msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+		msg.SetString(ParamKeyName, receiver.GetName())
+		if err := sender.SendMessage(msg, receiver.GetName()); err != nil {
+			t.Fatalf("send message fail: %s", err.Error())
+		}
+		select {
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s wait message timeout", receiver.GetName())
+		case received := <-receiver.MessageChan:
+			if name, _ := received.GetString(ParamKeyName); name != receiver.GetName() {
+				t.Fatalf("unexpected message for '%s' received by %s", name, receiver.GetName())
+			}
+			if received.GetSender() != sender.GetName() {
+				t.Fatalf("unexpected sender '%s'", received.GetSender())
+			}
+		}
+	}
+	exchange(core, peer)
+	exchange(peer, core)
+}
+
+//startMemoryPair start a stub and a connected peer, both stopped when test finished
+func startMemoryPair(t *testing.T, network *MemoryNetwork) (core, peer *memoryPeer) {
+	core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	peer = createMemoryPeer(t, network, ServiceTypeCell, 1)
+	if err := peer.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	waitMemoryEvent(t, peer.EventChan, "peer connect")
+	waitMemoryEvent(t, core.EventChan, "peer accepted")
+	t.Cleanup(func() {
+		if core.isRunning() {
+			core.Stop()
+		}
+		if peer.isRunning() {
+			peer.Stop()
+		}
+	})
+	return core, peer
+}
@@ -0,0 +1,147 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xtaci/kcp-go"
+	"time"
+)
+
+//EndpointOption customize EndpointService when created
+type EndpointOption interface {
+	apply(endpoint *EndpointService) error
+}
+
+type endpointOptionFunc func(endpoint *EndpointService) error
+
+func (f endpointOptionFunc) apply(endpoint *EndpointService) error {
+	return f(endpoint)
+}
+
+//WithEncryption encrypt all sessions with a key derived from secret and domain,
+//every endpoint in the domain must use the same method and secret
+func WithEncryption(method CryptMethod, secret string) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		if _, err := createBlockCrypt(method, secret, endpoint.domain); err != nil {
+			return err
+		}
+		var key = deriveCryptKey(secret, endpoint.domain)
+		endpoint.newCrypt = func() (kcp.BlockCrypt, error) {
+			return newBlockCrypt(method, key)
+		}
+		return nil
+	})
+}
+
+//WithTransport replace the default KCP transport
+func WithTransport(transport Transport) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		if nil == transport {
+			return errors.New("invalid transport")
+		}
+		endpoint.transport = transport
+		return nil
+	})
+}
+
+func applyEndpointOptions(endpoint *EndpointService, options []EndpointOption) error {
+	for _, option := range options {
+		if err := option.apply(endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//EndpointOptions tune timing and capacity of endpoint, zero field use default value
+type EndpointOptions struct {
+	KeepAliveInterval   time.Duration //interval of keep alive message
+	LostThreshold       time.Duration //service marked lost without keep alive
+	DisconnectThreshold time.Duration //lost service disconnected
+	CheckInterval       time.Duration //interval checking lost service
+	QueryDuration       time.Duration //timeout of stub discovery
+	RetryInterval       time.Duration //initial delay of stub recovery without ReconnectPolicy
+	StopTimeout         time.Duration //wait session routine finish when disconnect
+	HandshakeTimeout    time.Duration
+	PortRangeStart      int //listen port in [start, end)
+	PortRangeEnd        int
+	MessageQueueSize    int //incoming message and connection event
+	OutgoingQueueSize   int //outgoing message of each connection
+}
+
+const (
+	DefaultKeepAliveInterval   = 3 * time.Second
+	DefaultLostThreshold       = 9 * time.Second
+	DefaultDisconnectThreshold = 15 * time.Second
+	DefaultCheckInterval       = 5 * time.Second
+	DefaultQueryDuration       = 5 * time.Second
+	DefaultRetryInterval       = 3 * time.Second
+	DefaultStopTimeout         = 3 * time.Second
+	//remote endpoint must finish handshake in time
+	DefaultHandshakeTimeout = 5 * time.Second
+)
+
+func DefaultEndpointOptions() EndpointOptions {
+	return EndpointOptions{
+		KeepAliveInterval:   DefaultKeepAliveInterval,
+		LostThreshold:       DefaultLostThreshold,
+		DisconnectThreshold: DefaultDisconnectThreshold,
+		CheckInterval:       DefaultCheckInterval,
+		QueryDuration:       DefaultQueryDuration,
+		RetryInterval:       DefaultRetryInterval,
+		StopTimeout:         DefaultStopTimeout,
+		HandshakeTimeout:    DefaultHandshakeTimeout,
+		PortRangeStart:      ListenPortRangeStart,
+		PortRangeEnd:        ListenPortRangeEnd,
+		MessageQueueSize:    DefaultMessageQueueSize,
+		OutgoingQueueSize:   DefaultMessageQueueSize,
+	}
+}
+
+func (options EndpointOptions) apply(endpoint *EndpointService) error {
+	var defaults = DefaultEndpointOptions()
+	var durations = []struct {
+		name   string
+		value  *time.Duration
+		preset time.Duration
+	}{
+		{"keep alive interval", &options.KeepAliveInterval, defaults.KeepAliveInterval},
+		{"lost threshold", &options.LostThreshold, defaults.LostThreshold},
+		{"disconnect threshold", &options.DisconnectThreshold, defaults.DisconnectThreshold},
+		{"check interval", &options.CheckInterval, defaults.CheckInterval},
+		{"query duration", &options.QueryDuration, defaults.QueryDuration},
+		{"retry interval", &options.RetryInterval, defaults.RetryInterval},
+		{"stop timeout", &options.StopTimeout, defaults.StopTimeout},
+		{"handshake timeout", &options.HandshakeTimeout, defaults.HandshakeTimeout},
+	}
+	for _, duration := range durations {
+		if *duration.value < 0 {
+			return fmt.Errorf("invalid %s %s", duration.name, *duration.value)
+		} else if 0 == *duration.value {
+			*duration.value = duration.preset
+		}
+	}
+	if options.KeepAliveInterval >= options.LostThreshold {
+		return fmt.Errorf("lost threshold %s must be longer than keep alive interval %s",
+			options.LostThreshold, options.KeepAliveInterval)
+	}
+	if options.LostThreshold > options.DisconnectThreshold {
+		return fmt.Errorf("disconnect threshold %s shorter than lost threshold %s",
+			options.DisconnectThreshold, options.LostThreshold)
+	}
+	if 0 == options.PortRangeStart && 0 == options.PortRangeEnd {
+		options.PortRangeStart, options.PortRangeEnd = defaults.PortRangeStart, defaults.PortRangeEnd
+	}
+	if options.PortRangeStart <= 0 || options.PortRangeEnd <= options.PortRangeStart || options.PortRangeEnd > 1<<16 {
+		return fmt.Errorf("invalid port range %d ~ %d", options.PortRangeStart, options.PortRangeEnd)
+	}
+	for _, size := range []*int{&options.MessageQueueSize, &options.OutgoingQueueSize} {
+		if *size < 0 {
+			return fmt.Errorf("invalid queue size %d", *size)
+		} else if 0 == *size {
+			*size = DefaultMessageQueueSize
+		}
+	}
+	endpoint.options = options
+	return nil
+}
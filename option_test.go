@@ -0,0 +1,53 @@
+package framework
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_EndpointOptions(t *testing.T) {
+	var invalid = []EndpointOptions{
+		{KeepAliveInterval: time.Second, LostThreshold: time.Second},
+		{LostThreshold: 20 * time.Second},
+		{CheckInterval: -time.Second},
+		{PortRangeStart: 7000, PortRangeEnd: 6000},
+		{OutgoingQueueSize: -1},
+	}
+	for _, options := range invalid {
+		if _, err := CreatePeerEndpoint("", 0, "test", options, WithMemoryNetwork(CreateMemoryNetwork())); err == nil {
+			t.Fatalf("invalid options accepted: %+v", options)
+		} else {
+			t.Logf("options rejected: %s", err.Error())
+		}
+	}
+	//lab network with sub-second failover
+	var options = EndpointOptions{
+		KeepAliveInterval:   100 * time.Millisecond,
+		LostThreshold:       300 * time.Millisecond,
+		DisconnectThreshold: 500 * time.Millisecond,
+		CheckInterval:       100 * time.Millisecond,
+		RetryInterval:       200 * time.Millisecond,
+		PortRangeStart:      7000,
+		PortRangeEnd:        7010,
+		OutgoingQueueSize:   16,
+	}
+	var network = CreateMemoryNetwork()
+	endpoint, err := CreateStubEndpoint("", 0, "test", MemoryNetworkHost, options, WithMemoryNetwork(network))
+	if err != nil {
+		t.Fatalf("create endpoint fail: %s", err.Error())
+	}
+	if endpoint.options.QueryDuration != DefaultQueryDuration || endpoint.options.MessageQueueSize != DefaultMessageQueueSize {
+		t.Fatalf("default value not applied: %+v", endpoint.options)
+	}
+	var core = &memoryPeer{endpoint, make(chan bool, 8), make(chan Message, 8)}
+	core.handler = core
+	core.name = "Core_options"
+	if err = core.Start(); err != nil {
+		t.Fatalf("start fail: %s", err.Error())
+	}
+	defer core.Stop()
+	if !strings.HasSuffix(core.connectionListener.Addr().String(), ":7000") {
+		t.Fatalf("port range not applied: %s", core.connectionListener.Addr().String())
+	}
+}
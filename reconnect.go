@@ -0,0 +1,170 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+//ReconnectPolicy of peer endpoint recovering lost stub,
+//delay of nth attempt is InitialDelay * Multiplier^(n-1), limited by MaxDelay,
+//then randomized by Jitter so that peers do not retry in lockstep
+type ReconnectPolicy struct {
+	InitialDelay time.Duration
+	Multiplier   float64
+	MaxDelay     time.Duration
+	Jitter       float64 //fraction of delay randomized, in [0, 1]
+	MaxAttempts  int     //zero for unlimited
+}
+
+//ReconnectHandler optional for ServiceHandler, observe recovery of stub service
+type ReconnectHandler interface {
+	OnReconnectAttempt(attempt int, delay time.Duration)
+	//OnReconnectFailed invoked when an attempt fail, give up when max attempts reached
+	OnReconnectFailed(attempt int, err error, giveUp bool)
+}
+
+const (
+	DefaultReconnectMultiplier = 2.0
+	DefaultReconnectMaxDelay   = time.Minute
+	DefaultReconnectJitter     = 0.2
+)
+
+//DefaultReconnectPolicy start from retry interval of endpoint options
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{InitialDelay: DefaultRetryInterval, Multiplier: DefaultReconnectMultiplier,
+		MaxDelay: DefaultReconnectMaxDelay, Jitter: DefaultReconnectJitter}
+}
+
+func (policy ReconnectPolicy) apply(endpoint *EndpointService) error {
+	if policy.InitialDelay <= 0 {
+		return fmt.Errorf("invalid initial delay %s", policy.InitialDelay)
+	}
+	if policy.Multiplier < 1 {
+		return fmt.Errorf("invalid multiplier %f", policy.Multiplier)
+	}
+	if policy.MaxDelay < policy.InitialDelay {
+		return fmt.Errorf("max delay %s less than initial delay %s", policy.MaxDelay, policy.InitialDelay)
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return fmt.Errorf("invalid jitter %f", policy.Jitter)
+	}
+	if policy.MaxAttempts < 0 {
+		return fmt.Errorf("invalid max attempts %d", policy.MaxAttempts)
+	}
+	endpoint.reconnectPolicy = &policy
+	return nil
+}
+
+//delay before nth attempt, start from 1
+func (policy ReconnectPolicy) delay(attempt int, random *rand.Rand) time.Duration {
+	var delay = float64(policy.InitialDelay) * math.Pow(policy.Multiplier, float64(attempt-1))
+	if delay > float64(policy.MaxDelay) {
+		delay = float64(policy.MaxDelay)
+	}
+	if 0 != policy.Jitter {
+		//uniform in [delay * (1 - jitter), delay * (1 + jitter)]
+		delay = delay * (1 - policy.Jitter + 2*policy.Jitter*random.Float64())
+	}
+	return time.Duration(delay)
+}
+
+func (endpoint *EndpointService) getReconnectPolicy() ReconnectPolicy {
+	if nil != endpoint.reconnectPolicy {
+		return *endpoint.reconnectPolicy
+	}
+	var policy = DefaultReconnectPolicy()
+	policy.InitialDelay = endpoint.options.RetryInterval
+	if policy.MaxDelay < policy.InitialDelay {
+		policy.MaxDelay = policy.InitialDelay
+	}
+	return policy
+}
+
+func (endpoint *EndpointService) recoverStubService() {
+	if !atomic.CompareAndSwapInt32(&endpoint.recoveringStub, 0, 1) {
+		log.Println("<endpoint> recovery already in processing")
+		return
+	}
+	defer atomic.StoreInt32(&endpoint.recoveringStub, 0)
+	var policy = endpoint.getReconnectPolicy()
+	var random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	for attempt := 1; endpoint.isRunning(); attempt++ {
+		var delay = policy.delay(attempt, random)
+		time.Sleep(delay)
+		if !endpoint.isRunning() {
+			break
+		}
+		if endpoint.isStubAvailable() {
+			log.Println("<endpoint> stub service already recovered")
+			break
+		}
+		log.Printf("<endpoint> try recover stub service, attempt %d after %s...", attempt, delay)
+		endpoint.notifyReconnect(attempt, delay, nil, false)
+		var err = endpoint.connectAvailableStub()
+		if nil == err {
+			log.Println("<endpoint> stub service recovered")
+			break
+		}
+		log.Printf("<endpoint> recover fail: %s", err.Error())
+		var giveUp = 0 != policy.MaxAttempts && attempt >= policy.MaxAttempts
+		endpoint.notifyReconnect(attempt, delay, err, giveUp)
+		if giveUp {
+			log.Printf("<endpoint> give up recovering stub service after %d attempts", attempt)
+			break
+		}
+	}
+}
+
+func (endpoint *EndpointService) connectAvailableStub() error {
+	_, services, err := endpoint.locator.Locate(endpoint.options.QueryDuration)
+	if err != nil {
+		return err
+	}
+	var stubs = endpoint.stubs.update(endpoint.filterStubs(services))
+	if 0 == len(stubs) {
+		return errors.New("no stub available")
+	}
+	return endpoint.connectStubs(stubs)
+}
+
+//notifyReconnect deliver to ReconnectHandler in main routine
+func (endpoint *EndpointService) notifyReconnect(attempt int, delay time.Duration, reason error, giveUp bool) {
+	msg, err := CreateJsonMessage(ServiceReconnectEvent)
+	if err != nil {
+		log.Printf("<endpoint> create message fail:%s", err.Error())
+		return
+	}
+	msg.SetUInt(ParamKeyCount, uint(attempt))
+	msg.SetUInt(ParamKeyDelay, uint(delay.Milliseconds()))
+	if nil != reason {
+		msg.SetError(reason.Error())
+		msg.SetBoolean(ParamKeyFlag, giveUp)
+	}
+	if err = endpoint.SendToSelf(msg); err != nil {
+		log.Printf("<endpoint> notify reconnect event fail: %s", err.Error())
+	}
+}
+
+func (endpoint *EndpointService) handleReconnectEvent(msg Message) {
+	handler, implemented := endpoint.handler.(ReconnectHandler)
+	if !implemented {
+		return
+	}
+	attempt, err := msg.GetUInt(ParamKeyCount)
+	if err != nil {
+		log.Printf("<endpoint> get attempt fail:%s", err.Error())
+		return
+	}
+	if "" == msg.GetError() {
+		delay, _ := msg.GetUInt(ParamKeyDelay)
+		handler.OnReconnectAttempt(int(attempt), time.Duration(delay)*time.Millisecond)
+		return
+	}
+	giveUp, _ := msg.GetBoolean(ParamKeyFlag)
+	handler.OnReconnectFailed(int(attempt), errors.New(msg.GetError()), giveUp)
+}
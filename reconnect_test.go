@@ -0,0 +1,119 @@
+package framework
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+type reconnectPeer struct {
+	*memoryPeer
+	Attempts chan int
+	Failures chan bool
+}
+
+func (peer *reconnectPeer) OnReconnectAttempt(attempt int, delay time.Duration) {
+	peer.Attempts <- attempt
+}
+
+func (peer *reconnectPeer) OnReconnectFailed(attempt int, err error, giveUp bool) {
+	peer.Failures <- giveUp
+}
+
+func Test_ReconnectDelay(t *testing.T) {
+	var policy = ReconnectPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: 10 * time.Second}
+	var random = rand.New(rand.NewSource(1))
+	var expected = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second}
+	for index, delay := range expected {
+		if current := policy.delay(index+1, random); current != delay {
+			t.Fatalf("unexpected delay %s of attempt %d", current, index+1)
+		}
+	}
+	policy.Jitter = 0.5
+	for i := 0; i < testRepeat; i++ {
+		var delay = policy.delay(1, random)
+		if delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("delay %s out of jitter range", delay)
+		}
+	}
+}
+
+func createReconnectPeer(t *testing.T, network *MemoryNetwork, policy ReconnectPolicy) *reconnectPeer {
+	endpoint, err := CreatePeerEndpoint("", 0, "test", WithMemoryNetwork(network), policy)
+	if err != nil {
+		t.Fatalf("create peer fail: %s", err.Error())
+	}
+	var peer = &reconnectPeer{&memoryPeer{endpoint, make(chan bool, 8), make(chan Message, 8)},
+		make(chan int, 8), make(chan bool, 8)}
+	peer.handler = peer
+	peer.name = "Cell_reconnect"
+	peer.serviceType = ServiceTypeCell
+	return peer
+}
+
+func Test_ReconnectRecovered(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	var peer = createReconnectPeer(t, network, ReconnectPolicy{InitialDelay: 50 * time.Millisecond, Multiplier: 2,
+		MaxDelay: 200 * time.Millisecond, Jitter: 0.1})
+	if err := peer.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	defer peer.Stop()
+	waitMemoryEvent(t, peer.EventChan, "peer connect")
+	if err := core.Stop(); err != nil {
+		t.Fatalf("stop core fail: %s", err.Error())
+	}
+	waitMemoryEvent(t, peer.EventChan, "peer disconnect")
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("no reconnect attempt")
+	case attempt := <-peer.Attempts:
+		t.Logf("reconnect attempt %d", attempt)
+	}
+	//core restarted
+	core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("restart core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	waitMemoryEvent(t, peer.EventChan, "peer recovered")
+}
+
+func Test_ReconnectGiveUp(t *testing.T) {
+	const (
+		maxAttempts = 3
+	)
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	var peer = createReconnectPeer(t, network, ReconnectPolicy{InitialDelay: 10 * time.Millisecond, Multiplier: 1.5,
+		MaxDelay: 50 * time.Millisecond, MaxAttempts: maxAttempts})
+	if err := peer.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	defer peer.Stop()
+	waitMemoryEvent(t, peer.EventChan, "peer connect")
+	if err := core.Stop(); err != nil {
+		t.Fatalf("stop core fail: %s", err.Error())
+	}
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		select {
+		case <-time.After(time.Second):
+			t.Fatalf("wait failure of attempt %d timeout", attempt)
+		case giveUp := <-peer.Failures:
+			if giveUp != (maxAttempts == attempt) {
+				t.Fatalf("unexpected give up flag %t at attempt %d", giveUp, attempt)
+			}
+		}
+	}
+	var invalid = ReconnectPolicy{InitialDelay: time.Second, Multiplier: 0.5, MaxDelay: time.Second}
+	if _, err := CreatePeerEndpoint("", 0, "test", WithMemoryNetwork(network), invalid); err == nil {
+		t.Fatal("invalid reconnect policy accepted")
+	}
+}
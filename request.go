@@ -0,0 +1,131 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+//ErrEndpointStopped returned to request waiting when endpoint stopped
+var ErrEndpointStopped = errors.New("endpoint stopped")
+
+type requestKey struct {
+	Target      string
+	Transaction TransactionID
+	Response    MessageID
+}
+
+//requestResult response received, or error when request can not be answered
+type requestResult struct {
+	Response Message
+	Error    error
+}
+
+//requestTable correlate response to pending request
+type requestTable struct {
+	lastTransaction uint32
+	lock            sync.Mutex
+	waiters         map[requestKey]chan requestResult
+}
+
+func newRequestTable() *requestTable {
+	return &requestTable{waiters: map[requestKey]chan requestResult{}}
+}
+
+//responseOf message id of response to request
+func responseOf(request MessageID) MessageID {
+	return request>>ResourceOffset<<ResourceOffset | MessageResponse
+}
+
+func isRequest(id MessageID) bool {
+	return MessageRequest == id&(1<<ResourceOffset-1)
+}
+
+func isResponse(id MessageID) bool {
+	return MessageResponse == id&(1<<ResourceOffset-1)
+}
+
+func (table *requestTable) allocate(target string, request MessageID) (key requestKey, result chan requestResult) {
+	var id = TransactionID(atomic.AddUint32(&table.lastTransaction, 1))
+	if 0 == id {
+		//zero reserved for message without transaction
+		id = TransactionID(atomic.AddUint32(&table.lastTransaction, 1))
+	}
+	key = requestKey{target, id, responseOf(request)}
+	result = make(chan requestResult, 1)
+	table.lock.Lock()
+	table.waiters[key] = result
+	table.lock.Unlock()
+	return key, result
+}
+
+func (table *requestTable) release(key requestKey) {
+	table.lock.Lock()
+	delete(table.waiters, key)
+	table.lock.Unlock()
+}
+
+//deliver return false when no request waiting for msg
+func (table *requestTable) deliver(msg Message) bool {
+	if !isResponse(msg.GetID()) {
+		return false
+	}
+	var key = requestKey{msg.GetSender(), msg.GetTransactionID(), msg.GetID()}
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	result, exists := table.waiters[key]
+	if !exists {
+		return false
+	}
+	delete(table.waiters, key)
+	result <- requestResult{Response: msg}
+	return true
+}
+
+//cancelTarget fail all request waiting for response from target
+func (table *requestTable) cancelTarget(target string, reason error) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	for key, result := range table.waiters {
+		if key.Target != target {
+			continue
+		}
+		result <- requestResult{Error: reason}
+		delete(table.waiters, key)
+	}
+}
+
+//cancelAll wake up all waiting request
+func (table *requestTable) cancelAll() {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	for key, result := range table.waiters {
+		result <- requestResult{Error: ErrEndpointStopped}
+		delete(table.waiters, key)
+	}
+}
+
+//SendRequest send a clone of msg with a new transaction id to target, and wait for the response
+//with the same transaction, which will not be delivered to OnMessageReceived.
+//msg never modified, request failed when target disconnected before answered
+func (endpoint *EndpointService) SendRequest(ctx context.Context, msg Message, target string) (Message, error) {
+	if !isRequest(msg.GetID()) {
+		return nil, fmt.Errorf("message %08X is not a request", msg.GetID())
+	}
+	key, result := endpoint.requests.allocate(target, msg.GetID())
+	defer endpoint.requests.release(key)
+	var request = CloneJsonMessage(msg)
+	request.SetSender(msg.GetSender())
+	request.SetTransactionID(key.Transaction)
+	if err := endpoint.SendMessage(request, target); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case current := <-result:
+		return current.Response, current.Error
+	}
+}
@@ -0,0 +1,74 @@
+package framework
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_SendRequest(t *testing.T) {
+	core, peer := startMemoryPair(t, CreateMemoryNetwork())
+	//answer request in peer
+	go func() {
+		for msg := range peer.MessageChan {
+			if msg.GetID() != QueryComputePoolRequest {
+				continue
+			}
+			resp, _ := CreateJsonMessage(QueryComputePoolResponse)
+			resp.SetSuccess(true)
+			resp.SetTransactionID(msg.GetTransactionID())
+			resp.SetToSession(msg.GetFromSession())
+			peer.SendMessage(resp, msg.GetSender())
+		}
+	}()
+	for i := 0; i < testRepeat; i++ {
+		req, _ := CreateJsonMessage(QueryComputePoolRequest)
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		resp, err := core.SendRequest(ctx, req, peer.GetName())
+		cancel()
+		if err != nil {
+			t.Fatalf("%dth request fail: %s", i, err.Error())
+		}
+		if resp.GetID() != QueryComputePoolResponse || 0 == resp.GetTransactionID() {
+			t.Fatalf("unexpected response %08X with transaction %d", resp.GetID(), resp.GetTransactionID())
+		}
+		if 0 != req.GetTransactionID() {
+			t.Fatal("transaction of request modified")
+		}
+	}
+	select {
+	case msg := <-core.MessageChan:
+		t.Fatalf("response %08X delivered to handler", msg.GetID())
+	default:
+	}
+	//no response for another request
+	req, _ := CreateJsonMessage(GetComputePoolRequest)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := core.SendRequest(ctx, req, peer.GetName()); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected result of unanswered request: %v", err)
+	}
+	event, _ := CreateJsonMessage(ComputePoolReadyEvent)
+	if _, err := core.SendRequest(context.Background(), event, peer.GetName()); err == nil {
+		t.Fatal("event sent as request")
+	}
+	//pending request failed when target disconnected
+	var errChan = make(chan error, 1)
+	go func() {
+		req, _ := CreateJsonMessage(GetComputePoolRequest)
+		_, err := core.SendRequest(context.Background(), req, peer.GetName())
+		errChan <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := peer.Stop(); err != nil {
+		t.Fatalf("stop peer fail: %s", err.Error())
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending request not failed when target disconnected")
+	case err := <-errChan:
+		if nil == err {
+			t.Fatal("pending request answered by disconnected target")
+		}
+	}
+}
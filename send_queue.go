@@ -0,0 +1,253 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//OverflowPolicy decide what to do when outgoing queue of a connection is full
+type OverflowPolicy int
+
+const (
+	//OverflowBlock wait until queue available, or send timeout if configured
+	OverflowBlock OverflowPolicy = iota
+	//OverflowDropNewest discard message sending
+	OverflowDropNewest
+	//OverflowDropOldest discard the earliest message in queue
+	OverflowDropOldest
+	//OverflowDisconnect close connection of the slow service
+	OverflowDisconnect
+)
+
+var (
+	ErrQueueFull   = errors.New("outgoing queue full")
+	ErrSendTimeout = errors.New("send timeout")
+)
+
+//SendStatistic of the outgoing queue to a connected service
+type SendStatistic struct {
+	Pending  int //messages in queue
+	Capacity int
+	Queued   uint64
+	Dropped  uint64
+	Timeout  uint64
+	Overflow uint64 //times queue found full
+}
+
+type sendCounter struct {
+	queued   uint64
+	dropped  uint64
+	timeout  uint64
+	overflow uint64
+}
+
+//overflowSetting applied to outgoing queue of a connection
+type overflowSetting struct {
+	Policy  OverflowPolicy
+	Timeout time.Duration //only available for OverflowBlock, zero for waiting forever
+}
+
+func newOverflowSetting(policy OverflowPolicy, timeout time.Duration) (setting overflowSetting, err error) {
+	if policy < OverflowBlock || policy > OverflowDisconnect {
+		err = fmt.Errorf("invalid overflow policy %d", policy)
+		return
+	}
+	if timeout < 0 {
+		err = fmt.Errorf("invalid send timeout %s", timeout)
+		return
+	}
+	return overflowSetting{policy, timeout}, nil
+}
+
+//overflowTable default setting overridden by type or name of remote service, name first
+type overflowTable struct {
+	lock     sync.RWMutex
+	defaults overflowSetting
+	types    map[ServiceType]overflowSetting
+	names    map[string]overflowSetting
+}
+
+func newOverflowTable() *overflowTable {
+	return &overflowTable{types: map[ServiceType]overflowSetting{}, names: map[string]overflowSetting{}}
+}
+
+//resolve must call with lock held
+func (table *overflowTable) resolve(name string, t ServiceType) overflowSetting {
+	if setting, exists := table.names[name]; exists {
+		return setting
+	}
+	if setting, exists := table.types[t]; exists {
+		return setting
+	}
+	return table.defaults
+}
+
+//attach add connection with setting resolved, never overwritten by setting modified concurrently
+func (table *overflowTable) attach(connections *connectionTable, entry connEntry) bool {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	entry.Overflow = table.resolve(entry.Name, entry.Type)
+	return connections.add(entry)
+}
+
+//modify setting and apply to connections opened
+func (table *overflowTable) modify(connections *connectionTable, change func()) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	change()
+	for _, current := range connections.list() {
+		var setting = table.resolve(current.Name, current.Type)
+		connections.update(current.Name, func(entry *connEntry) {
+			entry.Overflow = setting
+		})
+	}
+}
+
+//WithOverflowPolicy set default policy when outgoing queue of any connection is full,
+//timeout only available for OverflowBlock, zero for waiting forever
+func WithOverflowPolicy(policy OverflowPolicy, timeout time.Duration) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		setting, err := newOverflowSetting(policy, timeout)
+		if err != nil {
+			return err
+		}
+		endpoint.overflows.defaults = setting
+		return nil
+	})
+}
+
+//SetOverflowPolicy override policy and send timeout of connection to target, also applied when target connected again
+func (endpoint *EndpointService) SetOverflowPolicy(target string, policy OverflowPolicy, timeout time.Duration) error {
+	setting, err := newOverflowSetting(policy, timeout)
+	if err != nil {
+		return err
+	}
+	endpoint.overflows.modify(endpoint.connections, func() {
+		endpoint.overflows.names[target] = setting
+	})
+	return nil
+}
+
+//SetTypeOverflowPolicy override policy and send timeout of connections to services with type t,
+//setting of target specified by SetOverflowPolicy preferred
+func (endpoint *EndpointService) SetTypeOverflowPolicy(t ServiceType, policy OverflowPolicy, timeout time.Duration) error {
+	setting, err := newOverflowSetting(policy, timeout)
+	if err != nil {
+		return err
+	}
+	endpoint.overflows.modify(endpoint.connections, func() {
+		endpoint.overflows.types[t] = setting
+	})
+	return nil
+}
+
+//TrySend never blocked, return ErrQueueFull when queue of target is full
+func (endpoint *EndpointService) TrySend(msg Message, target string) error {
+	if !endpoint.isRunning() {
+		return errors.New("endpoint closed")
+	}
+	if target == endpoint.name {
+		return endpoint.SendToSelf(msg)
+	}
+	if channel, exists := endpoint.connections.getSubmodule(target); exists {
+		select {
+		case channel <- msg:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+	entry, exists := endpoint.connections.get(target)
+	if !exists {
+		return fmt.Errorf("invalid target '%s'", target)
+	}
+	if send, err := endpoint.interceptOutbound(entry, msg); !send {
+		return err
+	}
+	select {
+	case entry.OutgoingChan <- msg:
+		atomic.AddUint64(&entry.Counter.queued, 1)
+		return nil
+	default:
+		atomic.AddUint64(&entry.Counter.overflow, 1)
+		atomic.AddUint64(&entry.Counter.dropped, 1)
+		return ErrQueueFull
+	}
+}
+
+//GetSendStatistic return outgoing queue status of a connected service
+func (endpoint *EndpointService) GetSendStatistic(target string) (statistic SendStatistic, err error) {
+	entry, exists := endpoint.connections.get(target)
+	if !exists {
+		err = fmt.Errorf("invalid target '%s'", target)
+		return
+	}
+	return entry.sendStatistic(), nil
+}
+
+func (entry connEntry) sendStatistic() (statistic SendStatistic) {
+	statistic.Pending = len(entry.OutgoingChan)
+	statistic.Capacity = cap(entry.OutgoingChan)
+	statistic.Queued = atomic.LoadUint64(&entry.Counter.queued)
+	statistic.Dropped = atomic.LoadUint64(&entry.Counter.dropped)
+	statistic.Timeout = atomic.LoadUint64(&entry.Counter.timeout)
+	statistic.Overflow = atomic.LoadUint64(&entry.Counter.overflow)
+	return statistic
+}
+
+//enqueue put message into outgoing queue of connection, apply overflow policy of connection when full
+func (endpoint *EndpointService) enqueue(entry connEntry, msg Message) error {
+	select {
+	case entry.OutgoingChan <- msg:
+		atomic.AddUint64(&entry.Counter.queued, 1)
+		return nil
+	default:
+	}
+	atomic.AddUint64(&entry.Counter.overflow, 1)
+	switch entry.Overflow.Policy {
+	case OverflowDropNewest:
+		atomic.AddUint64(&entry.Counter.dropped, 1)
+		return ErrQueueFull
+	case OverflowDropOldest:
+		for {
+			select {
+			case entry.OutgoingChan <- msg:
+				atomic.AddUint64(&entry.Counter.queued, 1)
+				return nil
+			default:
+			}
+			select {
+			case <-entry.OutgoingChan:
+				atomic.AddUint64(&entry.Counter.dropped, 1)
+			default:
+			}
+		}
+	case OverflowDisconnect:
+		atomic.AddUint64(&entry.Counter.dropped, 1)
+		log.Printf("<endpoint> outgoing queue of '%s' overflow, disconnect", entry.Name)
+		//serve routine will notify closed
+		entry.Session.Close()
+		return ErrQueueFull
+	default:
+		if 0 == entry.Overflow.Timeout {
+			entry.OutgoingChan <- msg
+			atomic.AddUint64(&entry.Counter.queued, 1)
+			return nil
+		}
+		var timer = time.NewTimer(entry.Overflow.Timeout)
+		defer timer.Stop()
+		select {
+		case entry.OutgoingChan <- msg:
+			atomic.AddUint64(&entry.Counter.queued, 1)
+			return nil
+		case <-timer.C:
+			atomic.AddUint64(&entry.Counter.timeout, 1)
+			atomic.AddUint64(&entry.Counter.dropped, 1)
+			return ErrSendTimeout
+		}
+	}
+}
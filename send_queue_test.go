@@ -0,0 +1,133 @@
+package framework
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func createStalledEntry(capacity int) connEntry {
+	client, _ := createMemoryConnPair("memory:1", "memory:2")
+	return connEntry{Name: "stalled", Status: connStatusConnected, Session: newFrameConn(client),
+		OutgoingChan: make(chan Message, capacity), Counter: &sendCounter{}}
+}
+
+func fillQueue(t *testing.T, endpoint *EndpointService, entry connEntry) {
+	for i := 0; i < cap(entry.OutgoingChan); i++ {
+		msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+		msg.SetUInt(ParamKeyIndex, uint(i))
+		if err := endpoint.enqueue(entry, msg); err != nil {
+			t.Fatalf("enqueue fail: %s", err.Error())
+		}
+	}
+}
+
+func Test_SendQueueOverflow(t *testing.T) {
+	const (
+		capacity    = 2
+		sendTimeout = 50 * time.Millisecond
+	)
+	var policies = []OverflowPolicy{OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowDisconnect}
+	for _, policy := range policies {
+		var endpoint = EndpointService{}
+		var entry = createStalledEntry(capacity)
+		entry.Overflow = overflowSetting{policy, sendTimeout}
+		fillQueue(t, &endpoint, entry)
+		var begin = time.Now()
+		msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+		msg.SetUInt(ParamKeyIndex, capacity)
+		var err = endpoint.enqueue(entry, msg)
+		switch policy {
+		case OverflowBlock:
+			if err != ErrSendTimeout || time.Since(begin) < sendTimeout {
+				t.Fatalf("unexpected result when blocked: %v", err)
+			}
+		case OverflowDropNewest:
+			if err != ErrQueueFull {
+				t.Fatalf("unexpected result when drop newest: %v", err)
+			}
+		case OverflowDropOldest:
+			if err != nil {
+				t.Fatalf("drop oldest fail: %s", err.Error())
+			}
+			first := <-entry.OutgoingChan
+			if index, _ := first.GetUInt(ParamKeyIndex); 1 != index {
+				t.Fatalf("unexpected message %d in head of queue", index)
+			}
+		case OverflowDisconnect:
+			if err != ErrQueueFull {
+				t.Fatalf("unexpected result when disconnect: %v", err)
+			}
+			if err = entry.Session.WriteMessage(msg); err == nil {
+				t.Fatal("session not closed")
+			}
+		}
+		if 1 != entry.Counter.overflow || 1 != entry.Counter.dropped {
+			t.Fatalf("unexpected counter with policy %d: %+v", policy, *entry.Counter)
+		}
+	}
+}
+
+func Test_TrySend(t *testing.T) {
+	core, peer := startMemoryPair(t, CreateMemoryNetwork())
+	go func() {
+		for range peer.MessageChan {
+		}
+	}()
+	msg, _ := CreateJsonMessage(ComputePoolReadyEvent)
+	if err := core.TrySend(msg, peer.GetName()); err != nil {
+		t.Fatalf("try send fail: %s", err.Error())
+	}
+	statistic, err := core.GetSendStatistic(peer.GetName())
+	if err != nil {
+		t.Fatalf("get statistic fail: %s", err.Error())
+	}
+	if 0 == statistic.Queued || DefaultMessageQueueSize != statistic.Capacity {
+		t.Fatalf("unexpected statistic: %+v", statistic)
+	}
+	if err = core.TrySend(msg, "invalid"); err == nil {
+		t.Fatal("send to invalid target")
+	}
+}
+
+func Test_OverflowOverride(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0, WithOverflowPolicy(OverflowBlock, 0))
+	if err := core.SetTypeOverflowPolicy(ServiceTypeCell, OverflowDisconnect, 0); err != nil {
+		t.Fatalf("set type policy fail: %s", err.Error())
+	}
+	if err := core.SetOverflowPolicy("invalid", OverflowDisconnect+1, 0); err == nil {
+		t.Fatal("invalid policy accepted")
+	}
+	if err := core.SetTypeOverflowPolicy(ServiceTypeCell, OverflowBlock, -time.Second); err == nil {
+		t.Fatal("invalid timeout accepted")
+	}
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	var cells []*memoryPeer
+	for i := 0; i < 2; i++ {
+		var cell = createMemoryPeer(t, network, ServiceTypeCell, byte(i+1))
+		if err := cell.Start(); err != nil {
+			t.Fatalf("start cell fail: %s", err.Error())
+		}
+		defer cell.Stop()
+		waitMemoryEvent(t, core.EventChan, fmt.Sprintf("%s accepted", cell.GetName()))
+		cells = append(cells, cell)
+	}
+	//stalled cell disconnected, while the other one keep waiting
+	if err := core.SetOverflowPolicy(cells[1].GetName(), OverflowBlock, 50*time.Millisecond); err != nil {
+		t.Fatalf("set policy fail: %s", err.Error())
+	}
+	var expected = []overflowSetting{{OverflowDisconnect, 0}, {OverflowBlock, 50 * time.Millisecond}}
+	for i, cell := range cells {
+		entry, exists := core.connections.get(cell.GetName())
+		if !exists {
+			t.Fatalf("%s not connected", cell.GetName())
+		}
+		if entry.Overflow != expected[i] {
+			t.Fatalf("unexpected setting of %s: %+v", cell.GetName(), entry.Overflow)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package framework
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//StaticLocator locate stubs from configured addresses instead of multicast,
+//address in "host:port" or "protocol://host:port" format, stubs tried in order
+type StaticLocator struct {
+	resolve func() ([]string, error)
+}
+
+const (
+	stubProtocolSeparator = "://"
+	stubFileComment       = "#"
+)
+
+//CreateStaticLocator use a fixed list of stub address
+func CreateStaticLocator(addresses ...string) (*StaticLocator, error) {
+	if 0 == len(addresses) {
+		return nil, errors.New("no stub address configured")
+	}
+	for _, address := range addresses {
+		if _, err := parseStubAddress(address); err != nil {
+			return nil, err
+		}
+	}
+	var stubs = make([]string, len(addresses))
+	copy(stubs, addresses)
+	return &StaticLocator{func() ([]string, error) {
+		return stubs, nil
+	}}, nil
+}
+
+//CreateFileLocator read stub address from file before each query,
+//one address per line, empty line or line start with '#' ignored
+func CreateFileLocator(path string) (*StaticLocator, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return &StaticLocator{func() ([]string, error) {
+		return readStubFile(path)
+	}}, nil
+}
+
+//CreateSRVLocator resolve stubs by DNS SRV record "_service._proto.name", ordered by priority and weight
+func CreateSRVLocator(service, proto, name string) (*StaticLocator, error) {
+	if "" == name {
+		return nil, errors.New("domain name required")
+	}
+	return &StaticLocator{func() ([]string, error) {
+		return lookupStubRecords(service, proto, name)
+	}}, nil
+}
+
+//WithStaticStubs connect peer endpoint to configured stubs without multicast
+func WithStaticStubs(addresses ...string) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) (err error) {
+		endpoint.locator, err = CreateStaticLocator(addresses...)
+		return
+	})
+}
+
+func (locator *StaticLocator) Locate(timeout time.Duration) (localAddress string, services []ServiceLocation, err error) {
+	addresses, err := locator.resolve()
+	if err != nil {
+		return
+	}
+	for _, address := range addresses {
+		service, err := parseStubAddress(address)
+		if err != nil {
+			return "", nil, err
+		}
+		services = append(services, service)
+	}
+	if 0 == len(services) {
+		err = errors.New("no stub address available")
+		return
+	}
+	//local address of route to stub
+	for _, service := range services {
+		if localAddress, err = localAddressTo(service.Address, service.Port); err == nil {
+			return localAddress, services, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no route to stub: %s", err.Error())
+}
+
+func parseStubAddress(address string) (service ServiceLocation, err error) {
+	service.Type = ServiceTypeStringCore
+	var hostPort = strings.TrimSpace(address)
+	if index := strings.Index(hostPort, stubProtocolSeparator); index >= 0 {
+		service.Protocol = hostPort[:index]
+		hostPort = hostPort[index+len(stubProtocolSeparator):]
+	}
+	host, portString, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		err = fmt.Errorf("invalid stub address '%s': %s", address, err.Error())
+		return
+	}
+	if service.Port, err = strconv.Atoi(portString); err != nil {
+		err = fmt.Errorf("invalid port in stub address '%s'", address)
+		return
+	}
+	service.Address = host
+	return service, nil
+}
+
+//localAddressTo select local address by route, no packet sent with UDP dial
+func localAddressTo(host string, port int) (string, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	localAddress, isUDP := conn.LocalAddr().(*net.UDPAddr)
+	if !isUDP {
+		return "", fmt.Errorf("unexpected local address %s", conn.LocalAddr().String())
+	}
+	return localAddress.IP.String(), nil
+}
+
+func readStubFile(path string) (addresses []string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	var scanner = bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		if "" == line || strings.HasPrefix(line, stubFileComment) {
+			continue
+		}
+		addresses = append(addresses, line)
+	}
+	return addresses, scanner.Err()
+}
+
+func lookupStubRecords(service, proto, name string) (addresses []string, err error) {
+	_, records, err := net.LookupSRV(service, proto, name)
+	if err != nil {
+		return
+	}
+	for _, record := range records {
+		var host = strings.TrimSuffix(record.Target, ".")
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+	return addresses, nil
+}
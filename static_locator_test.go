@@ -0,0 +1,83 @@
+package framework
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_StaticLocatorFile(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "stubs")
+	var content = "#stubs of test domain\n\n127.0.0.1:5600\ntcp://127.0.0.1:5601\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write stub file fail: %s", err.Error())
+	}
+	locator, err := CreateFileLocator(path)
+	if err != nil {
+		t.Fatalf("create locator fail: %s", err.Error())
+	}
+	localAddress, services, err := locator.Locate(0)
+	if err != nil {
+		t.Fatalf("locate fail: %s", err.Error())
+	}
+	if "127.0.0.1" != localAddress {
+		t.Fatalf("unexpected local address %s", localAddress)
+	}
+	var expected = []ServiceLocation{
+		{ServiceTypeStringCore, "", "127.0.0.1", 5600, 0},
+		{ServiceTypeStringCore, TransportProtocolTCP, "127.0.0.1", 5601, 0},
+	}
+	if len(services) != len(expected) {
+		t.Fatalf("%d service(s) located", len(services))
+	}
+	for index, service := range services {
+		if service != expected[index] {
+			t.Fatalf("unexpected service %v at %d", service, index)
+		}
+	}
+	if _, err = CreateStaticLocator("127.0.0.1"); err == nil {
+		t.Fatal("address without port accepted")
+	}
+}
+
+func Test_StaticLocatorFailover(t *testing.T) {
+	const (
+		stubHost = "127.0.0.1"
+	)
+	var network = CreateMemoryNetwork()
+	endpoint1, err := CreateStubEndpoint("", 0, "test", stubHost, WithMemoryNetwork(network))
+	if err != nil {
+		t.Fatalf("create stub fail: %s", err.Error())
+	}
+	var core = &memoryPeer{endpoint1, make(chan bool, 8), make(chan Message, 8)}
+	core.handler = core
+	if err = core.GenerateName(ServiceTypeCore, &net.Interface{HardwareAddr: net.HardwareAddr{0, 0, 0, 0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = core.Start(); err != nil {
+		t.Fatalf("start stub fail: %s", err.Error())
+	}
+	//first stub unavailable
+	endpoint2, err := CreatePeerEndpoint("", 0, "test", WithMemoryNetwork(network),
+		WithStaticStubs("memory://127.0.0.1:5700", "memory://127.0.0.1:5600"))
+	if err != nil {
+		t.Fatalf("create peer fail: %s", err.Error())
+	}
+	var peer = &memoryPeer{endpoint2, make(chan bool, 8), make(chan Message, 8)}
+	peer.handler = peer
+	if err = peer.GenerateName(ServiceTypeCell, &net.Interface{HardwareAddr: net.HardwareAddr{0, 0, 0, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = peer.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	waitMemoryEvent(t, peer.EventChan, "peer connect")
+	if err = core.Stop(); err != nil {
+		t.Fatalf("stop stub fail: %s", err.Error())
+	}
+	waitMemoryEvent(t, peer.EventChan, "peer disconnect")
+	if err = peer.Stop(); err != nil {
+		t.Fatalf("stop peer fail: %s", err.Error())
+	}
+}
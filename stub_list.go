@@ -0,0 +1,130 @@
+package framework
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//stubList ranked stubs known by peer endpoint, preferred first
+type stubList struct {
+	lock      sync.RWMutex
+	stubs     []ServiceLocation
+	current   ServiceLocation
+	connected bool
+	latency   map[string]time.Duration //smoothed round trip time of stubs ever connected
+}
+
+func newStubList() *stubList {
+	return &stubList{latency: map[string]time.Duration{}}
+}
+
+func stubAddress(stub ServiceLocation) string {
+	return fmt.Sprintf("%s:%d", stub.Address, stub.Port)
+}
+
+//rankStubs sort by priority, stub with lower latency preferred in the same priority,
+//keep order of discovery for stubs never measured
+func rankStubs(stubs []ServiceLocation, latency map[string]time.Duration) []ServiceLocation {
+	var ranked = make([]ServiceLocation, len(stubs))
+	copy(ranked, stubs)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Priority != ranked[j].Priority {
+			return ranked[i].Priority < ranked[j].Priority
+		}
+		//stub never measured ranked last
+		latency1, measured1 := latency[stubAddress(ranked[i])]
+		latency2, measured2 := latency[stubAddress(ranked[j])]
+		if measured1 && measured2 {
+			return latency1 < latency2
+		}
+		return measured1 && !measured2
+	})
+	return ranked
+}
+
+//update return ranked stubs
+func (list *stubList) update(stubs []ServiceLocation) []ServiceLocation {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	var ranked = rankStubs(stubs, list.latency)
+	list.stubs = ranked
+	var result = make([]ServiceLocation, len(ranked))
+	copy(result, ranked)
+	return result
+}
+
+//recordLatency of current stub
+func (list *stubList) recordLatency(latency time.Duration) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	if list.connected {
+		list.latency[stubAddress(list.current)] = latency
+	}
+}
+
+func (list *stubList) setCurrent(stub ServiceLocation) {
+	list.lock.Lock()
+	list.current = stub
+	list.connected = true
+	list.lock.Unlock()
+}
+
+//lose clear current stub and return it
+func (list *stubList) lose() (stub ServiceLocation, connected bool) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	stub, connected = list.current, list.connected
+	list.connected = false
+	return
+}
+
+//candidates ranked stubs except the lost one
+func (list *stubList) candidates(lost ServiceLocation) (stubs []ServiceLocation) {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+	for _, stub := range list.stubs {
+		if stub.Address == lost.Address && stub.Port == lost.Port {
+			continue
+		}
+		stubs = append(stubs, stub)
+	}
+	return stubs
+}
+
+func (list *stubList) all() []ServiceLocation {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+	var stubs = make([]ServiceLocation, len(list.stubs))
+	copy(stubs, list.stubs)
+	return stubs
+}
+
+//ListStubs return stubs known by peer endpoint, preferred first
+func (endpoint *EndpointService) ListStubs() []ServiceLocation {
+	return endpoint.stubs.all()
+}
+
+//failoverStub connect next stub in list immediately when current stub lost,
+//query again only when all known stubs unavailable
+func (endpoint *EndpointService) failoverStub() {
+	lost, _ := endpoint.stubs.lose()
+	if !atomic.CompareAndSwapInt32(&endpoint.recoveringStub, 0, 1) {
+		log.Println("<endpoint> recovery already in processing")
+		return
+	}
+	var candidates = endpoint.stubs.candidates(lost)
+	var err error
+	if 0 != len(candidates) {
+		log.Printf("<endpoint> stub %s:%d lost, failover to %d candidate(s)", lost.Address, lost.Port, len(candidates))
+		err = endpoint.connectStubs(candidates)
+	}
+	atomic.StoreInt32(&endpoint.recoveringStub, 0)
+	if 0 != len(candidates) && nil == err {
+		return
+	}
+	endpoint.recoverStubService()
+}
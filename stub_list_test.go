@@ -0,0 +1,76 @@
+package framework
+
+import (
+	"github.com/project-nano/sonar"
+	"testing"
+	"time"
+)
+
+func Test_StubPriority(t *testing.T) {
+	var location = parseSonarService(sonar.Service{Type: ServiceTypeStringCore, Protocol: "kcp;priority=5",
+		Address: "192.168.1.2", Port: 5600})
+	if TransportProtocolKCP != location.Protocol || 5 != location.Priority {
+		t.Fatalf("unexpected location %+v", location)
+	}
+	var stubs = []ServiceLocation{
+		{ServiceTypeStringCore, "", "192.168.1.1", 5600, 2},
+		{ServiceTypeStringCore, "", "192.168.1.2", 5600, 0},
+		{ServiceTypeStringCore, "", "192.168.1.3", 5600, 2},
+		{ServiceTypeStringCore, "", "192.168.1.4", 5600, 2},
+	}
+	var ranked = rankStubs(stubs, nil)
+	for index, address := range []string{"192.168.1.2", "192.168.1.1", "192.168.1.3", "192.168.1.4"} {
+		if ranked[index].Address != address {
+			t.Fatalf("unexpected stub %s at %d", ranked[index].Address, index)
+		}
+	}
+	//measured stub preferred in the same priority, lower latency first
+	ranked = rankStubs(stubs, map[string]time.Duration{
+		"192.168.1.1:5600": 30 * time.Millisecond,
+		"192.168.1.4:5600": 10 * time.Millisecond,
+	})
+	for index, address := range []string{"192.168.1.2", "192.168.1.4", "192.168.1.1", "192.168.1.3"} {
+		if ranked[index].Address != address {
+			t.Fatalf("unexpected stub %s at %d with latency", ranked[index].Address, index)
+		}
+	}
+}
+
+func Test_StubFailover(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	//standby published first
+	var standby = createMemoryPeer(t, network, ServiceTypeCore, 1, WithStubPriority(10))
+	if err := standby.Start(); err != nil {
+		t.Fatalf("start standby fail: %s", err.Error())
+	}
+	defer standby.Stop()
+	var primary = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := primary.Start(); err != nil {
+		t.Fatalf("start primary fail: %s", err.Error())
+	}
+	var peer = createMemoryPeer(t, network, ServiceTypeCell, 2)
+	if err := peer.Start(); err != nil {
+		t.Fatalf("start peer fail: %s", err.Error())
+	}
+	defer peer.Stop()
+	waitMemoryEvent(t, peer.EventChan, "peer connect")
+	if _, err := peer.GetPeerInfo(primary.GetName()); err != nil {
+		t.Fatalf("preferred stub not connected: %s", err.Error())
+	}
+	if stubs := peer.ListStubs(); 2 != len(stubs) || stubs[0].Priority != 0 {
+		t.Fatalf("unexpected stub list: %+v", stubs)
+	}
+	var lostTime = time.Now()
+	if err := primary.Stop(); err != nil {
+		t.Fatalf("stop primary fail: %s", err.Error())
+	}
+	waitMemoryEvent(t, peer.EventChan, "primary disconnect")
+	waitMemoryEvent(t, peer.EventChan, "failover")
+	//no query and retry delay
+	if elapsed := time.Since(lostTime); elapsed > DefaultRetryInterval {
+		t.Fatalf("failover takes %s", elapsed)
+	}
+	if _, err := peer.GetPeerInfo(standby.GetName()); err != nil {
+		t.Fatalf("standby stub not connected: %s", err.Error())
+	}
+}
@@ -0,0 +1,363 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+//Topic select published events by message id, or all events of a resource class
+type Topic struct {
+	ID       MessageID
+	Resource uint
+	Class    bool //match all events of Resource
+}
+
+const (
+	//flag of resource class in topic encoded
+	topicClassFlag = 1 << 32
+)
+
+//EventTopic match message with id
+func EventTopic(id MessageID) Topic {
+	return Topic{ID: id}
+}
+
+//ResourceTopic match all events of resource
+func ResourceTopic(resource uint) Topic {
+	return Topic{Resource: resource, Class: true}
+}
+
+func (topic Topic) Match(id MessageID) bool {
+	if !topic.Class {
+		return topic.ID == id
+	}
+	return MessageEvent == GetMessageType(id) && topic.Resource == GetMessageResource(id)
+}
+
+func (topic Topic) String() string {
+	if topic.Class {
+		return fmt.Sprintf("resource %d", topic.Resource)
+	}
+	return fmt.Sprintf("message %08X", uint32(topic.ID))
+}
+
+func (topic Topic) encode() uint64 {
+	if topic.Class {
+		return topicClassFlag | uint64(topic.Resource)
+	}
+	return uint64(topic.ID)
+}
+
+func decodeTopic(value uint64) Topic {
+	if 0 != value&topicClassFlag {
+		return ResourceTopic(uint(value &^ topicClassFlag))
+	}
+	return EventTopic(MessageID(value))
+}
+
+//subscriptionTable remote subscribers of local events, and local subscribers of remote events
+type subscriptionTable struct {
+	lock   sync.RWMutex
+	remote map[string]map[Topic]bool                 //remote name => topics
+	local  map[string]map[ServiceType]map[Topic]bool //subscriber("" for endpoint) => source => topics
+}
+
+func newSubscriptionTable() *subscriptionTable {
+	return &subscriptionTable{remote: map[string]map[Topic]bool{}, local: map[string]map[ServiceType]map[Topic]bool{}}
+}
+
+func (table *subscriptionTable) addRemote(name string, topics []Topic) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	subscribed, exists := table.remote[name]
+	if !exists {
+		subscribed = map[Topic]bool{}
+		table.remote[name] = subscribed
+	}
+	for _, topic := range topics {
+		subscribed[topic] = true
+	}
+}
+
+func (table *subscriptionTable) removeRemote(name string, topics []Topic) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	subscribed, exists := table.remote[name]
+	if !exists {
+		return
+	}
+	for _, topic := range topics {
+		delete(subscribed, topic)
+	}
+	if 0 == len(subscribed) {
+		delete(table.remote, name)
+	}
+}
+
+//dropRemote clear all topics when subscriber disconnected, subscribe again when reconnected
+func (table *subscriptionTable) dropRemote(name string) {
+	table.lock.Lock()
+	delete(table.remote, name)
+	table.lock.Unlock()
+}
+
+//subscribers of message id, sorted by name
+func (table *subscriptionTable) subscribers(id MessageID) (names []string) {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	for name, topics := range table.remote {
+		for topic := range topics {
+			if topic.Match(id) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//addLocal return topics not subscribed from source before
+func (table *subscriptionTable) addLocal(subscriber string, source ServiceType, topics []Topic) (added []Topic) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	for _, topic := range topics {
+		if !table.subscribedLocally(source, topic) {
+			added = append(added, topic)
+		}
+	}
+	sources, exists := table.local[subscriber]
+	if !exists {
+		sources = map[ServiceType]map[Topic]bool{}
+		table.local[subscriber] = sources
+	}
+	subscribed, exists := sources[source]
+	if !exists {
+		subscribed = map[Topic]bool{}
+		sources[source] = subscribed
+	}
+	for _, topic := range topics {
+		subscribed[topic] = true
+	}
+	return added
+}
+
+//removeLocal return topics no longer subscribed by any local subscriber
+func (table *subscriptionTable) removeLocal(subscriber string, source ServiceType, topics []Topic) (removed []Topic) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	if subscribed, exists := table.local[subscriber][source]; exists {
+		for _, topic := range topics {
+			delete(subscribed, topic)
+		}
+	}
+	for _, topic := range topics {
+		if !table.subscribedLocally(source, topic) {
+			removed = append(removed, topic)
+		}
+	}
+	return removed
+}
+
+//subscribedLocally must call with lock held
+func (table *subscriptionTable) subscribedLocally(source ServiceType, topic Topic) bool {
+	for _, sources := range table.local {
+		if sources[source][topic] {
+			return true
+		}
+	}
+	return false
+}
+
+//topicsOf source subscribed by any local subscriber
+func (table *subscriptionTable) topicsOf(source ServiceType) (topics []Topic) {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	var collected = map[Topic]bool{}
+	for _, sources := range table.local {
+		for topic := range sources[source] {
+			if !collected[topic] {
+				collected[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return topics
+}
+
+//localSubscribers of message from source, "" for endpoint itself
+func (table *subscriptionTable) localSubscribers(source ServiceType, id MessageID) (subscribers []string) {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	for subscriber, sources := range table.local {
+		for topic := range sources[source] {
+			if topic.Match(id) {
+				subscribers = append(subscribers, subscriber)
+				break
+			}
+		}
+	}
+	sort.Strings(subscribers)
+	return subscribers
+}
+
+//Subscribe events published by services with type source, subscribe again automatically when reconnected
+func (endpoint *EndpointService) Subscribe(source ServiceType, topics ...Topic) error {
+	return endpoint.subscribe("", source, topics)
+}
+
+//SubscribeFor deliver events published by services with type source to registered submodule
+func (endpoint *EndpointService) SubscribeFor(submodule string, source ServiceType, topics ...Topic) error {
+	if _, exists := endpoint.connections.getSubmodule(submodule); !exists {
+		return fmt.Errorf("invalid submodule '%s'", submodule)
+	}
+	return endpoint.subscribe(submodule, source, topics)
+}
+
+//Unsubscribe events of source subscribed by endpoint
+func (endpoint *EndpointService) Unsubscribe(source ServiceType, topics ...Topic) error {
+	return endpoint.unsubscribe("", source, topics)
+}
+
+//UnsubscribeFor events of source subscribed by submodule
+func (endpoint *EndpointService) UnsubscribeFor(submodule string, source ServiceType, topics ...Topic) error {
+	return endpoint.unsubscribe(submodule, source, topics)
+}
+
+//Publish send a clone of event to all subscribers of remote endpoint, marked as published so that
+//subscriber tell it from event sent point-to-point, return result of each subscriber, nil for message queued
+func (endpoint *EndpointService) Publish(msg Message) map[string]error {
+	var results = map[string]error{}
+	var published = CloneJsonMessage(msg)
+	published.SetSender(msg.GetSender())
+	published.SetBoolean(ParamKeyPublish, true)
+	for _, name := range endpoint.subscriptions.subscribers(msg.GetID()) {
+		entry, exists := endpoint.connections.get(name)
+		if !exists {
+			results[name] = fmt.Errorf("subscriber '%s' not connected", name)
+			continue
+		}
+		if !endpoint.isRunning() {
+			results[name] = errors.New("endpoint closed")
+			continue
+		}
+		results[name] = endpoint.enqueueClone(entry, published)
+	}
+	return results
+}
+
+func (endpoint *EndpointService) subscribe(subscriber string, source ServiceType, topics []Topic) error {
+	if 0 == len(topics) {
+		return errors.New("no topic specified")
+	}
+	var added = endpoint.subscriptions.addLocal(subscriber, source, topics)
+	if 0 == len(added) || !endpoint.isRunning() {
+		return nil
+	}
+	return endpoint.requestTopics(ServiceSubscribeEvent, source, added)
+}
+
+func (endpoint *EndpointService) unsubscribe(subscriber string, source ServiceType, topics []Topic) error {
+	if 0 == len(topics) {
+		return errors.New("no topic specified")
+	}
+	var removed = endpoint.subscriptions.removeLocal(subscriber, source, topics)
+	if 0 == len(removed) || !endpoint.isRunning() {
+		return nil
+	}
+	return endpoint.requestTopics(ServiceUnsubscribeEvent, source, removed)
+}
+
+//requestTopics send subscribe or unsubscribe to all connected services with type source
+func (endpoint *EndpointService) requestTopics(id MessageID, source ServiceType, topics []Topic) (err error) {
+	for _, entry := range endpoint.connections.list() {
+		if source != entry.Type {
+			continue
+		}
+		if sendError := endpoint.sendTopics(id, entry.Name, topics); sendError != nil {
+			log.Printf("<endpoint> send topics to '%s' fail: %s", entry.Name, sendError.Error())
+			err = sendError
+		}
+	}
+	return err
+}
+
+func (endpoint *EndpointService) sendTopics(id MessageID, target string, topics []Topic) error {
+	msg, err := CreateJsonMessage(id)
+	if err != nil {
+		return err
+	}
+	var values = make([]uint64, 0, len(topics))
+	for _, topic := range topics {
+		values = append(values, topic.encode())
+	}
+	msg.SetUIntArray(ParamKeyTopic, values)
+	return endpoint.SendMessage(msg, target)
+}
+
+//resubscribe when service with subscribed type connected
+func (endpoint *EndpointService) resubscribe(name string, source ServiceType) {
+	var topics = endpoint.subscriptions.topicsOf(source)
+	if 0 == len(topics) {
+		return
+	}
+	if err := endpoint.sendTopics(ServiceSubscribeEvent, name, topics); err != nil {
+		log.Printf("<endpoint> subscribe %d topic(s) from '%s' fail: %s", len(topics), name, err.Error())
+		return
+	}
+	log.Printf("<endpoint> %d topic(s) subscribed from '%s'", len(topics), name)
+}
+
+func (endpoint *EndpointService) handleTopicEvent(msg Message) {
+	values, err := msg.GetUIntArray(ParamKeyTopic)
+	if err != nil {
+		log.Printf("<endpoint> get topics fail: %s", err.Error())
+		return
+	}
+	var topics = make([]Topic, 0, len(values))
+	for _, value := range values {
+		topics = append(topics, decodeTopic(value))
+	}
+	var name = msg.GetSender()
+	if ServiceSubscribeEvent == msg.GetID() {
+		endpoint.subscriptions.addRemote(name, topics)
+		log.Printf("<endpoint> %d topic(s) subscribed by '%s'", len(topics), name)
+	} else {
+		endpoint.subscriptions.removeRemote(name, topics)
+		log.Printf("<endpoint> %d topic(s) unsubscribed by '%s'", len(topics), name)
+	}
+}
+
+//dispatchSubscribed deliver published event to subscribed submodules, return false when handler should receive it
+func (endpoint *EndpointService) dispatchSubscribed(incoming incomingMessage) bool {
+	var msg = incoming.Message
+	if !incoming.Remote {
+		return false
+	}
+	if published, _ := msg.GetBoolean(ParamKeyPublish); !published {
+		//sent point-to-point
+		return false
+	}
+	var subscribers = endpoint.subscriptions.localSubscribers(incoming.Type, msg.GetID())
+	if 0 == len(subscribers) {
+		return false
+	}
+	var toHandler = false
+	for _, subscriber := range subscribers {
+		if "" == subscriber {
+			toHandler = true
+			continue
+		}
+		channel, exists := endpoint.connections.getSubmodule(subscriber)
+		if !exists {
+			continue
+		}
+		var clone = CloneJsonMessage(msg)
+		clone.SetSender(msg.GetSender())
+		channel <- clone
+	}
+	return !toHandler
+}
@@ -0,0 +1,208 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_TopicMatch(t *testing.T) {
+	var topic = EventTopic(GuestStartedEvent)
+	if !topic.Match(GuestStartedEvent) || topic.Match(GuestStoppedEvent) {
+		t.Fatalf("unexpected match of %s", topic)
+	}
+	topic = ResourceTopic(ResourceInstance)
+	if !topic.Match(InstanceMigratedEvent) || !topic.Match(InstancePurgedEvent) || topic.Match(GuestStartedEvent) {
+		t.Fatalf("unexpected match of %s", topic)
+	}
+	for _, topic = range []Topic{EventTopic(DiskImageUpdatedEvent), ResourceTopic(ResourceGuest)} {
+		if decoded := decodeTopic(topic.encode()); decoded != topic {
+			t.Fatalf("%s decoded as %s", topic, decoded)
+		}
+	}
+}
+
+func waitSubscribers(t *testing.T, publisher *memoryPeer, id MessageID, count int) {
+	waitCondition(t, func() bool {
+		return count == len(publisher.subscriptions.subscribers(id))
+	}, "subscribers")
+}
+
+func receiveEvent(t *testing.T, channel chan Message, id MessageID) {
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatalf("wait event %08X timeout", uint32(id))
+	case msg := <-channel:
+		if id != msg.GetID() {
+			t.Fatalf("unexpected event %08X received", uint32(msg.GetID()))
+		}
+	}
+}
+
+func Test_Subscription(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	var cell = createMemoryPeer(t, network, ServiceTypeCell, 1,
+		ReconnectPolicy{InitialDelay: 50 * time.Millisecond, Multiplier: 1, MaxDelay: 50 * time.Millisecond})
+	var billing = make(chan Message, 8)
+	if err := cell.RegisterSubmodule("billing", billing); err != nil {
+		t.Fatal(err)
+	}
+	if err := cell.Subscribe(ServiceTypeCore, EventTopic(GuestStartedEvent)); err != nil {
+		t.Fatalf("subscribe fail: %s", err.Error())
+	}
+	if err := cell.SubscribeFor("billing", ServiceTypeCore, ResourceTopic(ResourceInstance)); err != nil {
+		t.Fatalf("subscribe for submodule fail: %s", err.Error())
+	}
+	if err := cell.SubscribeFor("monitor", ServiceTypeCore, ResourceTopic(ResourceInstance)); err == nil {
+		t.Fatal("subscribe for invalid submodule")
+	}
+	if err := cell.Start(); err != nil {
+		t.Fatalf("start cell fail: %s", err.Error())
+	}
+	defer cell.Stop()
+	waitSubscribers(t, core, GuestStartedEvent, 1)
+	waitSubscribers(t, core, InstanceMigratedEvent, 1)
+
+	var publish = func(id MessageID) map[string]error {
+		msg, _ := CreateJsonMessage(id)
+		var results = core.Publish(msg)
+		for name, err := range results {
+			if err != nil {
+				t.Fatalf("publish to '%s' fail: %s", name, err.Error())
+			}
+		}
+		return results
+	}
+	publish(GuestStartedEvent)
+	receiveEvent(t, cell.MessageChan, GuestStartedEvent)
+	publish(InstanceMigratedEvent)
+	receiveEvent(t, billing, InstanceMigratedEvent)
+	if results := publish(GuestStoppedEvent); 0 != len(results) {
+		t.Fatalf("unexpected subscribers %v", results)
+	}
+	select {
+	case msg := <-cell.MessageChan:
+		t.Fatalf("unexpected event %08X received by handler", uint32(msg.GetID()))
+	default:
+	}
+
+	//subscribe again after reconnected
+	if err := core.Stop(); err != nil {
+		t.Fatalf("stop core fail: %s", err.Error())
+	}
+	core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("restart core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	waitSubscribers(t, core, GuestStartedEvent, 1)
+	publish(GuestStartedEvent)
+	receiveEvent(t, cell.MessageChan, GuestStartedEvent)
+
+	if err := cell.Unsubscribe(ServiceTypeCore, EventTopic(GuestStartedEvent)); err != nil {
+		t.Fatalf("unsubscribe fail: %s", err.Error())
+	}
+	waitSubscribers(t, core, GuestStartedEvent, 0)
+	waitSubscribers(t, core, InstancePurgedEvent, 1)
+}
+
+func Test_ForgedEndpointEvent(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core, cell = startMemoryPair(t, network)
+	if err := cell.Subscribe(ServiceTypeCore, EventTopic(GuestStartedEvent)); err != nil {
+		t.Fatalf("subscribe fail: %s", err.Error())
+	}
+	waitSubscribers(t, core, GuestStartedEvent, 1)
+	var impostor = createMemoryPeer(t, network, ServiceTypeCell, 2)
+	if err := impostor.Start(); err != nil {
+		t.Fatalf("start impostor fail: %s", err.Error())
+	}
+	defer impostor.Stop()
+	waitMemoryEvent(t, impostor.EventChan, "impostor connect")
+	waitMemoryEvent(t, core.EventChan, "impostor accepted")
+	//events generated by endpoint itself never accepted from remote
+	for _, id := range []MessageID{ServiceDisconnectedEvent, ServiceConnectedEvent, ServiceReconnectEvent} {
+		forged, _ := CreateJsonMessage(id)
+		forged.SetString(ParamKeyName, cell.GetName())
+		forged.SetUInt(ParamKeyType, uint(ServiceTypeCell))
+		forged.SetString(ParamKeyAddress, "127.0.0.1")
+		if err := impostor.SendMessage(forged, core.GetName()); err != nil {
+			t.Fatalf("send forged event fail: %s", err.Error())
+		}
+	}
+	flushMainRoutine(t, core)
+	select {
+	case <-core.EventChan:
+		t.Fatal("forged event delivered to handler")
+	default:
+	}
+	if 1 != len(core.subscriptions.subscribers(GuestStartedEvent)) {
+		t.Fatal("subscription dropped by forged event")
+	}
+}
+
+func Test_PublishedAndDirectEvent(t *testing.T) {
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0)
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	var cell = createMemoryPeer(t, network, ServiceTypeCell, 1)
+	var billing = make(chan Message, 8)
+	if err := cell.RegisterSubmodule("billing", billing); err != nil {
+		t.Fatal(err)
+	}
+	if err := cell.SubscribeFor("billing", ServiceTypeCore, ResourceTopic(ResourceInstance)); err != nil {
+		t.Fatalf("subscribe for submodule fail: %s", err.Error())
+	}
+	if err := cell.Start(); err != nil {
+		t.Fatalf("start cell fail: %s", err.Error())
+	}
+	defer cell.Stop()
+	waitMemoryEvent(t, cell.EventChan, "cell connect")
+	waitMemoryEvent(t, core.EventChan, "cell accepted")
+	waitSubscribers(t, core, InstanceMigratedEvent, 1)
+	var publish = func() {
+		msg, _ := CreateJsonMessage(InstanceMigratedEvent)
+		for name, err := range core.Publish(msg) {
+			if err != nil {
+				t.Fatalf("publish to '%s' fail: %s", name, err.Error())
+			}
+		}
+	}
+	//event sent point-to-point never taken by subscriber
+	direct, _ := CreateJsonMessage(InstanceMigratedEvent)
+	if err := core.SendMessage(direct, cell.GetName()); err != nil {
+		t.Fatalf("send message fail: %s", err.Error())
+	}
+	receiveEvent(t, cell.MessageChan, InstanceMigratedEvent)
+	publish()
+	receiveEvent(t, billing, InstanceMigratedEvent)
+
+	//block main routine of cell, then publisher disconnected before event handled
+	for i := 0; i <= cap(cell.MessageChan); i++ {
+		msg, _ := CreateJsonMessage(GuestStartedEvent)
+		if err := core.SendMessage(msg, cell.GetName()); err != nil {
+			t.Fatalf("send message fail: %s", err.Error())
+		}
+	}
+	publish()
+	waitCondition(t, func() bool {
+		return 1 == len(cell.incomingMessageChan)
+	}, "event queued")
+	if err := core.Stop(); err != nil {
+		t.Fatalf("stop core fail: %s", err.Error())
+	}
+	waitCondition(t, func() bool {
+		_, exists := cell.connections.get(core.GetName())
+		return !exists
+	}, "connection removed")
+	for i := 0; i <= cap(cell.MessageChan); i++ {
+		receiveEvent(t, cell.MessageChan, GuestStartedEvent)
+	}
+	receiveEvent(t, billing, InstanceMigratedEvent)
+}
@@ -0,0 +1,92 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+//WithTransactionEngine let endpoint start and stop engine with itself, invoke task for message with executor
+//registered and push message addressed to a session, engine must not be started by caller
+func WithTransactionEngine(engine *TransactionEngine) EndpointOption {
+	return endpointOptionFunc(func(endpoint *EndpointService) error {
+		if nil == engine {
+			return errors.New("invalid transaction engine")
+		}
+		endpoint.engine = engine
+		return nil
+	})
+}
+
+//GetTransactionEngine return engine owned by endpoint, nil when not available
+func (endpoint *EndpointService) GetTransactionEngine() *TransactionEngine {
+	return endpoint.engine
+}
+
+//CreateErrorResponse create a failed response to request with reason, addressed to session of request
+func CreateErrorResponse(request Message, reason error) (Message, error) {
+	if !isRequest(request.GetID()) {
+		return nil, fmt.Errorf("message %08X is not a request", request.GetID())
+	}
+	resp, err := CreateJsonMessage(responseOf(request.GetID()))
+	if err != nil {
+		return nil, err
+	}
+	resp.SetSuccess(false)
+	resp.SetError(reason.Error())
+	resp.SetToSession(request.GetFromSession())
+	resp.SetTransactionID(request.GetTransactionID())
+	return resp, nil
+}
+
+func (endpoint *EndpointService) startEngine() error {
+	if nil == endpoint.engine {
+		return nil
+	}
+	return endpoint.engine.Start()
+}
+
+func (endpoint *EndpointService) stopEngine() {
+	if nil == endpoint.engine {
+		return
+	}
+	if err := endpoint.engine.Stop(); err != nil {
+		log.Printf("<endpoint> stop transaction engine fail: %s", err.Error())
+	}
+}
+
+//routeTransaction return false when message not accepted by engine
+func (endpoint *EndpointService) routeTransaction(msg Message) bool {
+	var engine = endpoint.engine
+	if nil == engine {
+		return false
+	}
+	var err error
+	if 0 != msg.GetToSession() {
+		err = engine.PushMessage(msg)
+	} else if engine.HasExecutor(msg.GetID()) {
+		err = engine.InvokeTask(msg)
+	} else {
+		return false
+	}
+	if err != nil {
+		log.Printf("<endpoint> route message %08X from '%s' fail: %s", uint32(msg.GetID()), msg.GetSender(), err.Error())
+		endpoint.replyError(msg, err)
+	}
+	return true
+}
+
+//replyError send error response when msg is a request
+func (endpoint *EndpointService) replyError(msg Message, reason error) {
+	if !isRequest(msg.GetID()) {
+		return
+	}
+	resp, err := CreateErrorResponse(msg, reason)
+	if err != nil {
+		log.Printf("<endpoint> create error response fail: %s", err.Error())
+		return
+	}
+	if err = endpoint.SendMessage(resp, msg.GetSender()); err != nil {
+		log.Printf("<endpoint> send error response to '%s' fail: %s", msg.GetSender(), err.Error())
+	}
+}
@@ -3,6 +3,7 @@ package framework
 import (
 	"fmt"
 	"log"
+	"sync"
 )
 
 type TransactionExecutor interface {
@@ -17,6 +18,7 @@ type sessionChannel struct {
 
 type TransactionEngine struct {
 	executorMap map[MessageID]TransactionExecutor
+	sessionLock sync.RWMutex
 	sessions    map[SessionID]sessionChannel
 	invokeChan  chan Message
 	pushChan    chan Message
@@ -60,6 +62,12 @@ func (engine *TransactionEngine) RegisterExecutor(initialMessage MessageID, exec
 	return nil
 }
 
+//HasExecutor check whether an executor bound with initial message
+func (engine *TransactionEngine) HasExecutor(initialMessage MessageID) bool {
+	_, exists := engine.executorMap[initialMessage]
+	return exists
+}
+
 func (engine *TransactionEngine)InvokeTask(message Message) error{
 	_, exists := engine.executorMap[message.GetID()]
 	if !exists{
@@ -74,7 +82,10 @@ func (engine *TransactionEngine)InvokeTask(message Message) error{
 
 func (engine *TransactionEngine)PushMessage(message Message) error{
 	id := message.GetToSession()
-	if session, exists := engine.sessions[id]; exists{
+	engine.sessionLock.RLock()
+	session, exists := engine.sessions[id]
+	engine.sessionLock.RUnlock()
+	if exists{
 		if session.Allocated{
 			//pre check
 			engine.pushChan <- message
@@ -133,7 +144,9 @@ func (engine *TransactionEngine)routine(){
 				var pushChan = make(chan Message, sessionQueueLength)
 				var tChan = make(chan bool, 1)
 				invoked = true
+				engine.sessionLock.Lock()
 				engine.sessions[id] = sessionChannel{true, pushChan, tChan}
+				engine.sessionLock.Unlock()
 				//log.Printf("<trans> [%08X] session allocated", id)
 				go executeTask(executor, id, msg, pushChan, tChan, engine.finishChan)
 				break
@@ -159,7 +172,9 @@ func (engine *TransactionEngine)routine(){
 			//deallocate session
 			if session, exists := engine.sessions[id]; exists {
 				if session.Allocated {
+					engine.sessionLock.Lock()
 					engine.sessions[id] = sessionChannel{Allocated:false}
+					engine.sessionLock.Unlock()
 					//log.Printf("<trans> [%08X] session deallocated", id)
 				}else{
 					log.Printf("<trans> warning: session [%08X] already deallocated", id)
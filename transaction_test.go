@@ -0,0 +1,107 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type sessionExecutor struct {
+	Started chan SessionID
+	Pushed  chan Message
+}
+
+func (executor *sessionExecutor) Execute(id SessionID, request Message, incoming chan Message, terminate chan bool) error {
+	executor.Started <- id
+	select {
+	case <-time.After(5 * time.Second):
+		return errors.New("wait pushed message timeout")
+	case msg := <-incoming:
+		executor.Pushed <- msg
+	}
+	return nil
+}
+
+func Test_CreateErrorResponse(t *testing.T) {
+	request, _ := CreateJsonMessage(QueryGuestRequest)
+	request.SetFromSession(3)
+	request.SetTransactionID(7)
+	resp, err := CreateErrorResponse(request, errors.New("invalid guest"))
+	if err != nil {
+		t.Fatalf("create error response fail: %s", err.Error())
+	}
+	if QueryGuestResponse != resp.GetID() || resp.IsSuccess() || "invalid guest" != resp.GetError() ||
+		3 != resp.GetToSession() || 7 != resp.GetTransactionID() {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	event, _ := CreateJsonMessage(GuestStartedEvent)
+	if _, err = CreateErrorResponse(event, errors.New("invalid guest")); err == nil {
+		t.Fatal("error response created for event")
+	}
+}
+
+func Test_EndpointTransaction(t *testing.T) {
+	engine, _ := CreateTransactionEngine()
+	var executor = &sessionExecutor{Started: make(chan SessionID, 1), Pushed: make(chan Message, 1)}
+	if err := engine.RegisterExecutor(QueryGuestRequest, executor); err != nil {
+		t.Fatal(err)
+	}
+	if !engine.HasExecutor(QueryGuestRequest) || engine.HasExecutor(QueryZoneRequest) {
+		t.Fatal("unexpected executor")
+	}
+	var network = CreateMemoryNetwork()
+	var core = createMemoryPeer(t, network, ServiceTypeCore, 0, WithTransactionEngine(engine))
+	if err := core.Start(); err != nil {
+		t.Fatalf("start core fail: %s", err.Error())
+	}
+	defer core.Stop()
+	var cell = createMemoryPeer(t, network, ServiceTypeCell, 1)
+	if err := cell.Start(); err != nil {
+		t.Fatalf("start cell fail: %s", err.Error())
+	}
+	defer cell.Stop()
+	waitMemoryEvent(t, cell.EventChan, "peer connect")
+	waitMemoryEvent(t, core.EventChan, "peer accepted")
+	var send = func(msg Message) {
+		if err := cell.SendMessage(msg, core.GetName()); err != nil {
+			t.Fatalf("send message fail: %s", err.Error())
+		}
+	}
+	request, _ := CreateJsonMessage(QueryGuestRequest)
+	send(request)
+	var session SessionID
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait session timeout")
+	case session = <-executor.Started:
+	}
+	event, _ := CreateJsonMessage(GuestStartedEvent)
+	event.SetToSession(session)
+	send(event)
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait pushed message timeout")
+	case msg := <-executor.Pushed:
+		if GuestStartedEvent != msg.GetID() {
+			t.Fatalf("unexpected message %08X pushed", uint32(msg.GetID()))
+		}
+	}
+	//request to invalid session
+	request, _ = CreateJsonMessage(QueryZoneRequest)
+	request.SetToSession(minSessionID + sessionCount)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := cell.SendRequest(ctx, request, core.GetName())
+	if err != nil {
+		t.Fatalf("send request fail: %s", err.Error())
+	}
+	if resp.IsSuccess() || "" == resp.GetError() {
+		t.Fatal("error response expected")
+	}
+	//finished session dropped, event without executor delivered to handler
+	send(event)
+	event, _ = CreateJsonMessage(GuestStartedEvent)
+	send(event)
+	receiveEvent(t, core.MessageChan, GuestStartedEvent)
+}
@@ -0,0 +1,142 @@
+package framework
+
+import (
+	"fmt"
+	"github.com/xtaci/kcp-go"
+	"io"
+	"net"
+	"time"
+)
+
+//TransportConn is a reliable, ordered byte stream between endpoints
+type TransportConn interface {
+	io.ReadWriteCloser
+	RemoteAddr() net.Addr
+	SetReadDeadline(t time.Time) error
+}
+
+type TransportListener interface {
+	Accept() (TransportConn, error)
+	Close() error
+	Addr() net.Addr
+}
+
+//Transport create connections for EndpointService, address in "host:port" format
+type Transport interface {
+	//Protocol published with service, peer only connect to stub with the same protocol
+	Protocol() string
+	Listen(address string) (TransportListener, error)
+	Dial(address string) (TransportConn, error)
+}
+
+const (
+	TransportProtocolKCP = "kcp"
+	TransportProtocolTCP = "tcp"
+	DefaultDialTimeout   = 5 * time.Second
+)
+
+//prepareTransport use KCP by default, and apply encryption configured
+func (endpoint *EndpointService) prepareTransport() error {
+	if nil == endpoint.transport {
+		endpoint.transport = &KCPTransport{NewCrypt: endpoint.newCrypt}
+		return nil
+	}
+	if nil == endpoint.newCrypt {
+		return nil
+	}
+	kcpTransport, isKCP := endpoint.transport.(*KCPTransport)
+	if !isKCP {
+		return fmt.Errorf("encryption not available for transport '%s'", endpoint.transport.Protocol())
+	}
+	if nil == kcpTransport.Crypt && nil == kcpTransport.NewCrypt {
+		kcpTransport.NewCrypt = endpoint.newCrypt
+	}
+	return nil
+}
+
+//KCPTransport is the default transport, sessions encrypted when Crypt or NewCrypt available,
+//crypt serialized when shared by sessions
+type KCPTransport struct {
+	Crypt    kcp.BlockCrypt
+	NewCrypt func() (kcp.BlockCrypt, error)
+}
+
+//blockCrypt create crypt for each listener and outgoing session, crypt of listener shared by all sessions accepted
+func (transport *KCPTransport) blockCrypt() (kcp.BlockCrypt, error) {
+	if nil != transport.NewCrypt {
+		crypt, err := transport.NewCrypt()
+		if err != nil {
+			return nil, err
+		}
+		return newLockedBlockCrypt(crypt), nil
+	}
+	return newLockedBlockCrypt(transport.Crypt), nil
+}
+
+type kcpListener struct {
+	*kcp.Listener
+}
+
+func (listener *kcpListener) Accept() (TransportConn, error) {
+	return listener.AcceptKCP()
+}
+
+func (transport *KCPTransport) Protocol() string {
+	return TransportProtocolKCP
+}
+
+func (transport *KCPTransport) Listen(address string) (TransportListener, error) {
+	crypt, err := transport.blockCrypt()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := kcp.ListenWithOptions(address, crypt, DefaultDataShards, DefaultParityShards)
+	if err != nil {
+		return nil, err
+	}
+	return &kcpListener{listener}, nil
+}
+
+func (transport *KCPTransport) Dial(address string) (TransportConn, error) {
+	crypt, err := transport.blockCrypt()
+	if err != nil {
+		return nil, err
+	}
+	return kcp.DialWithOptions(address, crypt, DefaultDataShards, DefaultParityShards)
+}
+
+//TCPTransport for networks rate-limiting UDP
+type TCPTransport struct {
+}
+
+type tcpListener struct {
+	*net.TCPListener
+}
+
+func (listener *tcpListener) Accept() (TransportConn, error) {
+	return listener.AcceptTCP()
+}
+
+func (transport *TCPTransport) Protocol() string {
+	return TransportProtocolTCP
+}
+
+func (transport *TCPTransport) Listen(address string) (TransportListener, error) {
+	tcpAddress, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenTCP("tcp", tcpAddress)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpListener{listener}, nil
+}
+
+func (transport *TCPTransport) Dial(address string) (TransportConn, error) {
+	conn, err := net.DialTimeout("tcp", address, DefaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.TCPConn), nil
+}
@@ -0,0 +1,60 @@
+package framework
+
+import (
+	"testing"
+)
+
+func checkTransport(t *testing.T, transport Transport) {
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen with %s fail: %s", transport.Protocol(), err.Error())
+	}
+	defer listener.Close()
+	client, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial with %s fail: %s", transport.Protocol(), err.Error())
+	}
+	defer client.Close()
+	var sender = newFrameConn(client)
+	origin, err := generateMessage()
+	if err != nil {
+		t.Fatalf("generate message fail: %s", err.Error())
+	}
+	generateStringArrayParam(origin, 20)
+	//packed in one write
+	for i := 0; i < 2; i++ {
+		if err = sender.WriteMessage(origin); err != nil {
+			t.Fatalf("write message with %s fail: %s", transport.Protocol(), err.Error())
+		}
+	}
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept with %s fail: %s", transport.Protocol(), err.Error())
+	}
+	defer server.Close()
+	if _, _, err = splitAddress(server.RemoteAddr()); err != nil {
+		t.Fatalf("invalid remote address: %s", err.Error())
+	}
+	var receiver = newFrameConn(server)
+	for i := 0; i < 2; i++ {
+		received, err := receiver.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %dth message with %s fail: %s", i, transport.Protocol(), err.Error())
+		}
+		identical, err := isIdentical(origin, CloneJsonMessage(received), nil)
+		if err != nil {
+			t.Fatalf("compare message fail: %s", err.Error())
+		}
+		if !identical {
+			t.Fatalf("%dth message corrupted with %s", i, transport.Protocol())
+		}
+	}
+}
+
+func Test_KCPTransport(t *testing.T) {
+	checkTransport(t, &KCPTransport{})
+}
+
+func Test_TCPTransport(t *testing.T) {
+	checkTransport(t, &TCPTransport{})
+}